@@ -0,0 +1,280 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ * A value of an Accept, Accept-Language or Accept-Charset header with its q value
+ */
+type QualityValue struct {
+	Value   string
+	Quality float64
+}
+
+/**
+ * Builds an Accept, Accept-Language or Accept-Charset header with q values, e.g.
+ * <pre>NewAcceptHeader().Add("application/vnd.acme.v2+json", 1).Add("application/json", 0.5)</pre>
+ */
+type AcceptHeader struct {
+	name   string
+	values []QualityValue
+}
+
+/**
+ * @return a builder for the Accept header
+ */
+func NewAcceptHeader() *AcceptHeader {
+	var header = new(AcceptHeader)
+	header.name = ACCEPT
+	return header
+}
+
+/**
+ * @return a builder for the Accept-Language header
+ */
+func NewAcceptLanguageHeader() *AcceptHeader {
+	var header = new(AcceptHeader)
+	header.name = ACCEPT_LANGUAGE
+	return header
+}
+
+/**
+ * @return a builder for the Accept-Charset header
+ */
+func NewAcceptCharsetHeader() *AcceptHeader {
+	var header = new(AcceptHeader)
+	header.name = ACCEPT_CHARSET
+	return header
+}
+
+/**
+ * Add a value. Adding a value again replaces its q value
+ * @param value a media type, language tag or charset
+ * @param quality the q value, clamped between 0 and 1 and rounded to three decimals. 0 means not acceptable
+ * @return this builder
+ */
+func (a *AcceptHeader) Add(value string, quality float64) *AcceptHeader {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return a
+	}
+	if quality < 0 {
+		quality = 0
+	}
+	if quality > 1 {
+		quality = 1
+	}
+	quality = float64(int(quality*1000+0.5)) / 1000
+	for i, existing := range a.values {
+		if strings.EqualFold(existing.Value, value) {
+			a.values[i].Quality = quality
+			return a
+		}
+	}
+	a.values = append(a.values, QualityValue{Value: value, Quality: quality})
+	return a
+}
+
+/**
+ * Add values with q 1
+ * @param values media types, language tags or charsets
+ * @return this builder
+ */
+func (a *AcceptHeader) Prefer(values ...string) *AcceptHeader {
+	for _, value := range values {
+		a.Add(value, 1)
+	}
+	return a
+}
+
+/**
+ * @return the name of the header, Accept, Accept-Language or Accept-Charset
+ */
+func (a *AcceptHeader) GetName() string {
+	return a.name
+}
+
+/**
+ * @return the values in the order they were added
+ */
+func (a *AcceptHeader) GetValues() []QualityValue {
+	return append([]QualityValue(nil), a.values...)
+}
+
+/**
+ * @return the header value ordered by q value, q=1 is left out
+ */
+func (a *AcceptHeader) String() string {
+	values := a.GetValues()
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Quality > values[j].Quality
+	})
+	parts := make([]string, 0, len(values))
+	for _, value := range values {
+		parts = append(parts, formatQuality(value.Value, value.Quality))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatQuality(value string, quality float64) string {
+	if quality >= 1 {
+		return value
+	}
+	return value + ";q=" + strconv.FormatFloat(quality, 'f', -1, 64)
+}
+
+/**
+ * Parse an Accept, Accept-Language or Accept-Charset header. Values without a q parameter have q 1,
+ * other parameters stay part of the value.
+ * @param header the header value
+ * @return the values in the order of the header
+ */
+func ParseAcceptHeader(header string) []QualityValue {
+	values := make([]QualityValue, 0)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		value := QualityValue{Value: strings.TrimSpace(params[0]), Quality: 1}
+		if value.Value == "" {
+			continue
+		}
+		for _, param := range params[1:] {
+			name, raw, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(strings.TrimSpace(name), "q") {
+				quality, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+				if err != nil || quality < 0 || quality > 1 {
+					quality = 0
+				}
+				value.Quality = quality
+			} else {
+				value.Value += ";" + strings.TrimSpace(param)
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+/**
+ * Server side content negotiation: pick the offered content type the client prefers according to its Accept header.
+ * Each offer gets the q value of the most specific matching range, ties go to the earlier offer.
+ * @param accept the Accept header of the request, empty accepts anything, a header without a valid media range nothing
+ * @param offers the content types the server can produce, in order of preference
+ * @return the content type to respond with, false when nothing offered is acceptable
+ */
+func NegotiateContentType(accept string, offers ...ContentType) (ContentType, bool) {
+	if strings.TrimSpace(accept) == "" && len(offers) > 0 {
+		return offers[0], true
+	}
+	ranges := make([]MediaType, 0)
+	qualities := make([]float64, 0)
+	for _, value := range ParseAcceptHeader(accept) {
+		media, err := ParseContentType(value.Value)
+		if err == nil {
+			ranges = append(ranges, media)
+			qualities = append(qualities, value.Quality)
+		}
+	}
+	index := negotiate(len(ranges), len(offers), func(r int, o int) (int, bool) {
+		offer, err := offers[o].Parse()
+		if err != nil || !offer.Matches(ranges[r]) {
+			return 0, false
+		}
+		return specificity(ranges[r])*100 + len(ranges[r].Params), true
+	}, qualities)
+	if index < 0 {
+		return "", false
+	}
+	return offers[index], true
+}
+
+/**
+ * Server side negotiation of Accept-Language. A range matches a tag when it is equal to the tag
+ * or to a prefix of it ending at a hyphen, so en matches en-US, and * matches anything.
+ * @param accept the Accept-Language header of the request, empty accepts anything, a header without a valid value nothing
+ * @param offers the language tags the server has, in order of preference
+ * @return the language to respond in, false when nothing offered is acceptable
+ */
+func NegotiateLanguage(accept string, offers ...string) (string, bool) {
+	if strings.TrimSpace(accept) == "" && len(offers) > 0 {
+		return offers[0], true
+	}
+	values := ParseAcceptHeader(accept)
+	qualities := make([]float64, len(values))
+	for i, value := range values {
+		qualities[i] = value.Quality
+	}
+	index := negotiate(len(values), len(offers), func(r int, o int) (int, bool) {
+		tag := strings.ToLower(offers[o])
+		language := strings.ToLower(values[r].Value)
+		switch {
+		case language == "*":
+			return 0, true
+		case tag == language || strings.HasPrefix(tag, language+"-"):
+			return len(language), true
+		}
+		return 0, false
+	}, qualities)
+	if index < 0 {
+		return "", false
+	}
+	return offers[index], true
+}
+
+/**
+ * Server side negotiation of Accept-Charset. Names are compared case insensitively and * matches anything.
+ * @param accept the Accept-Charset header of the request, empty accepts anything, a header without a valid value nothing
+ * @param offers the charsets the server can encode in, in order of preference
+ * @return the charset to respond in, false when nothing offered is acceptable
+ */
+func NegotiateCharset(accept string, offers ...string) (string, bool) {
+	if strings.TrimSpace(accept) == "" && len(offers) > 0 {
+		return offers[0], true
+	}
+	values := ParseAcceptHeader(accept)
+	qualities := make([]float64, len(values))
+	for i, value := range values {
+		qualities[i] = value.Quality
+	}
+	index := negotiate(len(values), len(offers), func(r int, o int) (int, bool) {
+		switch {
+		case values[r].Value == "*":
+			return 0, true
+		case strings.EqualFold(values[r].Value, offers[o]):
+			return 1, true
+		}
+		return 0, false
+	}, qualities)
+	if index < 0 {
+		return "", false
+	}
+	return offers[index], true
+}
+
+/**
+ * @param ranges the number of valid values in the header, 0 accepts nothing
+ * @param offers the number of offers
+ * @param match reports if range r covers offer o and how specific it is
+ * @param qualities the q value of each range
+ * @return the index of the best offer, -1 when none is acceptable
+ */
+func negotiate(ranges int, offers int, match func(r int, o int) (int, bool), qualities []float64) int {
+	if offers == 0 {
+		return -1
+	}
+	best, bestQuality := -1, 0.0
+	for o := 0; o < offers; o++ {
+		quality, specific := 0.0, -1
+		for r := 0; r < ranges; r++ {
+			if rank, ok := match(r, o); ok && rank > specific {
+				quality, specific = qualities[r], rank
+			}
+		}
+		if quality > bestQuality {
+			best, bestQuality = o, quality
+		}
+	}
+	return best
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAcceptHeaderBuilder(t *testing.T) {
+	header := NewAcceptHeader().
+		Add("application/json", 0.5).
+		Prefer("application/vnd.acme.v2+json").
+		Add("text/plain", 0.1234).
+		Add("*/*", -1).
+		Add("  ", 1).
+		Add("APPLICATION/JSON", 0.8)
+	if header.GetName() != ACCEPT {
+		t.Errorf("name %q", header.GetName())
+	}
+	if value := header.String(); value != "application/vnd.acme.v2+json, application/json;q=0.8, text/plain;q=0.123, */*;q=0" {
+		t.Errorf("got %q", value)
+	}
+	values := header.GetValues()
+	if len(values) != 4 || values[0] != (QualityValue{"application/json", 0.8}) {
+		t.Errorf("values %+v", values)
+	}
+	values[0].Quality = 0
+	if header.GetValues()[0].Quality != 0.8 {
+		t.Error("GetValues returned the values of the builder")
+	}
+
+	if name := NewAcceptLanguageHeader().GetName(); name != ACCEPT_LANGUAGE {
+		t.Errorf("language builder %q", name)
+	}
+	if value := NewAcceptCharsetHeader().Add("utf-8", 2).Add("iso-8859-1", 0.5).String(); value != "utf-8, iso-8859-1;q=0.5" {
+		t.Errorf("charset builder %q", value)
+	}
+}
+
+func TestParseAcceptHeader(t *testing.T) {
+	values := ParseAcceptHeader("text/html;level=1, application/json ; Q=0.5,, */*;q=bad, text/plain;q=2")
+	expected := []QualityValue{
+		{"text/html;level=1", 1},
+		{"application/json", 0.5},
+		{"*/*", 0},
+		{"text/plain", 0},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("got %+v", values)
+	}
+	if values := ParseAcceptHeader(""); len(values) != 0 {
+		t.Errorf("empty header: %+v", values)
+	}
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	tests := []struct {
+		accept   string
+		offers   []ContentType
+		expected ContentType
+		ok       bool
+	}{
+		{"", []ContentType{APPLICATION_XML, APPLICATION_JSON}, APPLICATION_XML, true},
+		{" ", []ContentType{APPLICATION_XML, APPLICATION_JSON}, APPLICATION_XML, true},
+		// a header which names nothing valid is not the same as no header
+		{"not a media range", []ContentType{APPLICATION_JSON}, "", false},
+		{";q=1", []ContentType{APPLICATION_JSON}, "", false},
+		{"application/json, application/xml;q=0.9", []ContentType{APPLICATION_XML, APPLICATION_JSON}, APPLICATION_JSON, true},
+		{"application/*;q=0.5, application/xml", []ContentType{APPLICATION_JSON, APPLICATION_XML}, APPLICATION_XML, true},
+		// the most specific range decides, even when a wider one has a higher q
+		{"*/*, application/json;q=0", []ContentType{APPLICATION_JSON, TEXT_PLAIN}, TEXT_PLAIN, true},
+		{"application/json, text/plain", []ContentType{TEXT_PLAIN, APPLICATION_JSON}, TEXT_PLAIN, true},
+		{"application/*+json", []ContentType{APPLICATION_XML, APPLICATION_PROBLEM_JSON}, APPLICATION_PROBLEM_JSON, true},
+		{"image/png", []ContentType{APPLICATION_JSON}, "", false},
+		{"application/json", nil, "", false},
+	}
+	for _, test := range tests {
+		if contentType, ok := NegotiateContentType(test.accept, test.offers...); contentType != test.expected || ok != test.ok {
+			t.Errorf("%q with %v: got %q, %v", test.accept, test.offers, contentType, ok)
+		}
+	}
+}
+
+func TestNegotiateLanguageAndCharset(t *testing.T) {
+	if language, ok := NegotiateLanguage("de;q=0.5, en", "de-DE", "en-US"); language != "en-US" || !ok {
+		t.Errorf("got %q, %v", language, ok)
+	}
+	if language, ok := NegotiateLanguage("en-GB, en;q=0.8, *;q=0.1", "en-US", "fr"); language != "en-US" || !ok {
+		t.Errorf("got %q, %v", language, ok)
+	}
+	if language, ok := NegotiateLanguage("e", "en"); ok {
+		t.Errorf("a partial subtag matched: %q", language)
+	}
+	if charset, ok := NegotiateCharset("ISO-8859-1;q=0.5, *;q=0.1", "utf-8", "iso-8859-1"); charset != "iso-8859-1" || !ok {
+		t.Errorf("got %q, %v", charset, ok)
+	}
+	if language, ok := NegotiateLanguage(",", "en"); ok {
+		t.Errorf("a header without values matched: %q", language)
+	}
+	if charset, ok := NegotiateCharset("utf-8;q=0", "utf-8"); ok {
+		t.Errorf("q=0 was accepted: %q", charset)
+	}
+}
+
+func TestNegotiationBetweenClientAndServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		chosen, ok := NegotiateContentType(r.Header.Get(ACCEPT), APPLICATION_JSON, APPLICATION_XML)
+		if !ok {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set(CONTENT_TYPE, string(chosen))
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	tests := []struct {
+		name        string
+		request     HttpRequest
+		status      int
+		contentType string
+	}{
+		{"preferred", client.Get(server.URL).WithAccept(NewAcceptHeader().Add("application/json", 0.5).Add("application/xml", 0.9)), 200, "application/xml"},
+		{"wildcard", client.Get(server.URL).WithAccept(NewAcceptHeader().Add("text/html", 1).Add("*/*", 0.1)), 200, "application/json"},
+		{"none sent", client.Get(server.URL), 200, "application/json"},
+		{"nothing offered", client.Get(server.URL).WithAccept(NewAcceptHeader().Add("text/html", 1)), 406, ""},
+		{"unparseable", client.Get(server.URL).Accept("not a media range"), 406, ""},
+	}
+	for _, tt := range tests {
+		response := tt.request.AsEmpty()
+		headers := response.GetHeaders()
+		if response.GetStatus() != tt.status || headers.GetFirst(CONTENT_TYPE) != tt.contentType {
+			t.Errorf("%s: got %d %q, want %d %q", tt.name, response.GetStatus(), headers.GetFirst(CONTENT_TYPE), tt.status, tt.contentType)
+		}
+	}
+}
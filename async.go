@@ -0,0 +1,288 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var ErrExecutorShutdown = errors.New("fiftyrest: async executor is shut down")
+
+/**
+ * A unit of work run by the AsyncExecutor. The context is cancelled when
+ * the caller's context is done or the Future is cancelled.
+ */
+type AsyncTask func(ctx context.Context) (HttpResponse, error)
+
+/**
+ * Callback for the result of an async request. Exactly one of the methods is invoked, once.
+ */
+type Callback interface {
+
+	/**
+	 * Called when the request returned a response
+	 * @param response the response
+	 */
+	Completed(response HttpResponse)
+
+	/**
+	 * Called when the request could not be executed
+	 * @param e the error
+	 */
+	Failed(e error)
+
+	/**
+	 * Called when the request was cancelled through its context or Future.Cancel
+	 */
+	Cancelled()
+}
+
+/**
+ * Adapts plain functions to a Callback. Nil functions are skipped.
+ */
+type CallbackFuncs struct {
+	OnCompleted func(response HttpResponse)
+	OnFailed    func(e error)
+	OnCancelled func()
+}
+
+func (c CallbackFuncs) Completed(response HttpResponse) {
+	if c.OnCompleted != nil {
+		c.OnCompleted(response)
+	}
+}
+
+func (c CallbackFuncs) Failed(e error) {
+	if c.OnFailed != nil {
+		c.OnFailed(e)
+	}
+}
+
+func (c CallbackFuncs) Cancelled() {
+	if c.OnCancelled != nil {
+		c.OnCancelled()
+	}
+}
+
+/**
+ * The pending result of an async request.
+ */
+type Future struct {
+	done      chan struct{}
+	once      sync.Once
+	cancel    context.CancelFunc
+	callback  Callback
+	response  HttpResponse
+	err       error
+	cancelled bool
+}
+
+func newFuture(cancel context.CancelFunc, callback Callback) *Future {
+	var future = new(Future)
+	future.done = make(chan struct{})
+	future.cancel = cancel
+	future.callback = callback
+	return future
+}
+
+func (f *Future) complete(response HttpResponse, err error, cancelled bool) bool {
+	completed := false
+	f.once.Do(func() {
+		completed = true
+		f.response = response
+		f.err = err
+		f.cancelled = cancelled
+		close(f.done)
+		f.cancel()
+	})
+	// outside of once.Do, a callback may call Cancel which would otherwise wait on the Once forever
+	if !completed || f.callback == nil {
+		return completed
+	}
+	switch {
+	case cancelled:
+		f.callback.Cancelled()
+	case err != nil:
+		f.callback.Failed(err)
+	default:
+		f.callback.Completed(response)
+	}
+	return completed
+}
+
+/**
+ * Wait for the response.
+ * @param ctx bounds how long to wait, it does not cancel the request
+ * @return the response, or the error the request failed with. A cancelled request returns a CancelledError
+ */
+func (f *Future) Get(ctx context.Context) (HttpResponse, error) {
+	select {
+	case <-f.done:
+		return f.response, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+/**
+ * @return a channel which is closed once the request has completed, failed or been cancelled
+ */
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+/**
+ * Cancel the request. Has no effect if it already finished.
+ * @return true if this call cancelled the request
+ */
+func (f *Future) Cancel() bool {
+	return f.complete(nil, &CancelledError{Cause: context.Canceled}, true)
+}
+
+/**
+ * @return true if the request was cancelled
+ */
+func (f *Future) IsCancelled() bool {
+	select {
+	case <-f.done:
+		return f.cancelled
+	default:
+		return false
+	}
+}
+
+type asyncJob struct {
+	ctx    context.Context
+	task   AsyncTask
+	future *Future
+}
+
+/**
+ * A bounded pool of goroutines executing async requests.
+ */
+type AsyncExecutor struct {
+	jobs      chan asyncJob
+	closed    chan struct{}
+	closeOnce sync.Once
+	lock      sync.RWMutex
+	running   sync.WaitGroup
+}
+
+/**
+ * Start a pool of workers.
+ * @param workers the number of requests executed at the same time, at least 1
+ * @param queueSize the number of requests waiting for a worker before Submit blocks
+ * @return the executor
+ */
+func NewAsyncExecutor(workers int, queueSize int) *AsyncExecutor {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+	var executor = new(AsyncExecutor)
+	executor.jobs = make(chan asyncJob, queueSize)
+	executor.closed = make(chan struct{})
+	executor.running.Add(workers)
+	for i := 0; i < workers; i++ {
+		go executor.work()
+	}
+	return executor
+}
+
+/**
+ * Queue a task. Blocks while the queue is full until ctx is done.
+ * @param ctx cancels the task while it is queued or running
+ * @param task the request to execute
+ * @param callback notified of the result, may be nil
+ * @return a Future for the result
+ */
+func (e *AsyncExecutor) Submit(ctx context.Context, task AsyncTask, callback Callback) *Future {
+	ctx, cancel := context.WithCancel(ctx)
+	future := newFuture(cancel, callback)
+
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	// the select below picks a ready case at random, without this check a task could be queued after Shutdown drained the queue
+	select {
+	case <-e.closed:
+		future.complete(nil, ErrExecutorShutdown, false)
+		return future
+	default:
+	}
+	select {
+	case <-e.closed:
+		future.complete(nil, ErrExecutorShutdown, false)
+	case <-ctx.Done():
+		future.complete(nil, &CancelledError{Cause: ctx.Err()}, true)
+	case e.jobs <- asyncJob{ctx: ctx, task: task, future: future}:
+	}
+	return future
+}
+
+func (e *AsyncExecutor) work() {
+	defer e.running.Done()
+	for {
+		select {
+		case <-e.closed:
+			return
+		case job := <-e.jobs:
+			e.run(job)
+		}
+	}
+}
+
+func (e *AsyncExecutor) run(job asyncJob) {
+	select {
+	case <-e.closed:
+		job.future.complete(nil, ErrExecutorShutdown, false)
+		return
+	default:
+	}
+	if err := job.ctx.Err(); err != nil {
+		job.future.complete(nil, &CancelledError{Cause: err}, true)
+		return
+	}
+	response, err := job.task(job.ctx)
+	if err != nil && job.ctx.Err() != nil {
+		job.future.complete(nil, &CancelledError{Cause: job.ctx.Err()}, true)
+		return
+	}
+	job.future.complete(response, err, false)
+}
+
+/**
+ * Stop accepting tasks and wait for running tasks to finish.
+ * Tasks still waiting in the queue fail with ErrExecutorShutdown.
+ * @param ctx bounds how long to wait for running tasks
+ * @return ctx.Err() if running tasks did not finish in time
+ */
+func (e *AsyncExecutor) Shutdown(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.closed)
+	})
+
+	e.lock.Lock()
+	for drained := false; !drained; {
+		select {
+		case job := <-e.jobs:
+			job.future.complete(nil, ErrExecutorShutdown, false)
+		default:
+			drained = true
+		}
+	}
+	e.lock.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		e.running.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAsyncExecutorRunsTasks(t *testing.T) {
+	executor := NewAsyncExecutor(2, 4)
+	defer executor.Shutdown(context.Background())
+
+	failure := errors.New("boom")
+	var failed atomic.Int32
+	future := executor.Submit(context.Background(), func(ctx context.Context) (HttpResponse, error) {
+		return nil, failure
+	}, CallbackFuncs{OnFailed: func(e error) { failed.Add(1) }})
+
+	if _, err := future.Get(context.Background()); !errors.Is(err, failure) {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if failed.Load() != 1 {
+		t.Fatalf("OnFailed called %d times", failed.Load())
+	}
+}
+
+func TestAsyncExecutorCancel(t *testing.T) {
+	executor := NewAsyncExecutor(1, 1)
+	defer executor.Shutdown(context.Background())
+
+	started := make(chan struct{})
+	future := executor.Submit(context.Background(), func(ctx context.Context) (HttpResponse, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, nil)
+	<-started
+	if !future.Cancel() || !future.IsCancelled() {
+		t.Fatal("Cancel() did not cancel the running task")
+	}
+	var cancelled *CancelledError
+	if _, err := future.Get(context.Background()); !errors.As(err, &cancelled) {
+		t.Fatalf("Get() error = %v", err)
+	}
+}
+
+func TestAsyncExecutorSubmitAfterShutdown(t *testing.T) {
+	executor := NewAsyncExecutor(2, 10)
+	if err := executor.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		future := executor.Submit(context.Background(), func(ctx context.Context) (HttpResponse, error) {
+			return nil, nil
+		}, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := future.Get(ctx)
+		cancel()
+		if !errors.Is(err, ErrExecutorShutdown) {
+			t.Fatalf("submit %d after shutdown: %v", i, err)
+		}
+	}
+}
+
+func TestAsyncCallbackMayCancelItsFuture(t *testing.T) {
+	executor := NewAsyncExecutor(1, 1)
+	var future *Future
+	ready := make(chan struct{})
+	future = executor.Submit(context.Background(), func(ctx context.Context) (HttpResponse, error) {
+		<-ready
+		return nil, nil
+	}, CallbackFuncs{OnCompleted: func(response HttpResponse) {
+		if future.Cancel() {
+			t.Error("Cancel() after completion returned true")
+		}
+	}})
+	close(ready)
+	future.Get(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := executor.Shutdown(ctx); err != nil {
+		t.Fatalf("worker stuck in the callback: %v", err)
+	}
+}
+
+func TestAsyncRequestsOfTheClient(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}
+		w.Header().Set(CONTENT_TYPE, string(APPLICATION_JSON))
+		io.WriteString(w, `{"id":1}`)
+	}))
+	defer server.Close()
+	defer close(release)
+	client := newTestClient(t, NewDefaultConfig())
+
+	completed := make(chan HttpResponse, 1)
+	future := client.Get(server.URL).AsStringAsyncWithCallback(context.Background(), CallbackFuncs{
+		OnCompleted: func(response HttpResponse) { completed <- response },
+	})
+	response, err := future.Get(context.Background())
+	if err != nil || response.GetBody() != `{"id":1}` || <-completed != response {
+		t.Fatalf("got %v: %v", response, err)
+	}
+	response, err = client.Get(server.URL).AsObjectAsync(context.Background()).Get(context.Background())
+	if err != nil || response.GetBody().(map[string]interface{})["id"] != 1.0 {
+		t.Errorf("got %v: %v", response, err)
+	}
+	response, err = client.Get(server.URL).AsJsonAsync(context.Background()).Get(context.Background())
+	if err != nil || response.GetBody().(*JsonNode).GetObject()["id"] != 1.0 {
+		t.Errorf("got %v: %v", response, err)
+	}
+
+	var cancelled atomic.Int32
+	future = client.Get(server.URL+"/slow").AsEmptyAsyncWithCallback(context.Background(), CallbackFuncs{
+		OnCancelled: func() { cancelled.Add(1) },
+	})
+	future.Cancel()
+	var cancelErr *CancelledError
+	if _, err := future.Get(context.Background()); !errors.As(err, &cancelErr) || cancelled.Load() != 1 {
+		t.Errorf("got %v, OnCancelled called %d times", err, cancelled.Load())
+	}
+}
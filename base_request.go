@@ -0,0 +1,647 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+/**
+ * The HttpRequest of this package, created by the Get, Post, ... methods of HttpClient.
+ * The builder methods change the request and return it, the As* methods send it with the client it was created by.
+ */
+type BaseRequest struct {
+	config *Config
+	client Client
+	method HttpMethod
+	// the url as it was given, may hold {name} route params and be relative to Config.DefaultBaseUrl
+	url         string
+	routeParams map[string]string
+	query       []queryParam
+	// the url of the current page while AsPaged or Pages follow the links, "" otherwise
+	target           string
+	headers          Headers
+	body             Body
+	objectMapper     ObjectMapper
+	responseEncoding string
+	compress         *bool
+	decompress       *bool
+	expected         []int
+	allowedHopByHop  []string
+	timeouts         Timeouts
+	proxy            Proxy
+	ctx              context.Context
+	downloadMonitor  ProgressMonitor
+	creationTime     time.Time
+}
+
+type queryParam struct {
+	name  string
+	value string
+}
+
+/**
+ * @param config the current config
+ * @param client sends the request
+ * @param method the HTTP method
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func NewBaseRequest(config *Config, client Client, method HttpMethod, url string) *BaseRequest {
+	var request = new(BaseRequest)
+	request.config = config
+	request.client = client
+	request.method = method
+	request.url = url
+	request.routeParams = make(map[string]string)
+	request.headers = *NewHeaders()
+	request.creationTime = time.Now().UTC()
+	return request
+}
+
+/**
+ * Set the body of the request
+ * @param body the body
+ * @return this request builder
+ */
+func (r *BaseRequest) WithBody(body Body) *BaseRequest {
+	r.body = body
+	return r
+}
+
+func (r *BaseRequest) RouteParam(name string, value string) HttpRequest {
+	r.routeParams[name] = value
+	return r
+}
+
+func (r *BaseRequest) RouteParamWithParameters(params map[string]interface{}) HttpRequest {
+	for name, value := range params {
+		r.RouteParam(name, fmt.Sprint(value))
+	}
+	return r
+}
+
+func (r *BaseRequest) BasicAuth(username string, password string) HttpRequest {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return r.HeaderReplace(AUTHORIZATION, "Basic "+credentials)
+}
+
+func (r *BaseRequest) Accept(value string) HttpRequest {
+	return r.HeaderReplace(ACCEPT, value)
+}
+
+func (r *BaseRequest) WithAccept(accept *AcceptHeader) HttpRequest {
+	return r.HeaderReplace(accept.GetName(), accept.String())
+}
+
+func (r *BaseRequest) ResponseEncoding(encoding string) HttpRequest {
+	r.responseEncoding = encoding
+	return r
+}
+
+func (r *BaseRequest) CompressRequest(enabled bool) HttpRequest {
+	r.compress = &enabled
+	return r
+}
+
+func (r *BaseRequest) DecompressResponse(enabled bool) HttpRequest {
+	r.decompress = &enabled
+	return r
+}
+
+func (r *BaseRequest) ExpectStatus(codes ...int) HttpRequest {
+	r.expected = codes
+	return r
+}
+
+func (r *BaseRequest) Header(name string, value string) HttpRequest {
+	r.headers.Add(name, value)
+	return r
+}
+
+func (r *BaseRequest) HeaderReplace(name string, value string) HttpRequest {
+	r.headers.Replace(name, value)
+	return r
+}
+
+func (r *BaseRequest) AllowHopByHop(names ...string) HttpRequest {
+	r.allowedHopByHop = append(r.allowedHopByHop, names...)
+	return r
+}
+
+func (r *BaseRequest) Headers(headerMap map[string]interface{}) HttpRequest {
+	for name, value := range headerMap {
+		r.Header(name, fmt.Sprint(value))
+	}
+	return r
+}
+
+func (r *BaseRequest) Cookie(name string, value string) HttpRequest {
+	return r.CookieAsCookie(Cookie{Name: name, value: value})
+}
+
+func (r *BaseRequest) CookieAsCookie(cookie Cookie) HttpRequest {
+	r.headers.Cookie(cookie)
+	return r
+}
+
+func (r *BaseRequest) Cookies(cookies []Cookie) HttpRequest {
+	r.headers.CookieAll(cookies)
+	return r
+}
+
+func (r *BaseRequest) QueryString(name string, value interface{}) HttpRequest {
+	r.query = append(r.query, queryParam{name: name, value: fmt.Sprint(value)})
+	return r
+}
+
+func (r *BaseRequest) QueryStringWithValues(name string, values []interface{}) HttpRequest {
+	for _, value := range values {
+		r.QueryString(name, value)
+	}
+	return r
+}
+
+func (r *BaseRequest) QueryStringWithParameters(parameters map[string]interface{}) HttpRequest {
+	for name, value := range parameters {
+		r.QueryString(name, value)
+	}
+	return r
+}
+
+func (r *BaseRequest) WithObjectMapper(mapper ObjectMapper) HttpRequest {
+	r.objectMapper = mapper
+	return r
+}
+
+func (r *BaseRequest) SocketTimeout(millies int) HttpRequest {
+	return r.IdleReadTimeout(time.Duration(millies) * time.Millisecond)
+}
+
+func (r *BaseRequest) ConnectTimeout(millies int) HttpRequest {
+	return r.DialTimeout(time.Duration(millies) * time.Millisecond)
+}
+
+func (r *BaseRequest) DialTimeout(timeout time.Duration) HttpRequest {
+	r.timeouts.Dial = timeout
+	return r
+}
+
+func (r *BaseRequest) TLSHandshakeTimeout(timeout time.Duration) HttpRequest {
+	r.timeouts.TLSHandshake = timeout
+	return r
+}
+
+func (r *BaseRequest) ResponseHeaderTimeout(timeout time.Duration) HttpRequest {
+	r.timeouts.ResponseHeader = timeout
+	return r
+}
+
+func (r *BaseRequest) IdleReadTimeout(timeout time.Duration) HttpRequest {
+	r.timeouts.IdleRead = timeout
+	return r
+}
+
+func (r *BaseRequest) TotalTimeout(timeout time.Duration) HttpRequest {
+	r.timeouts.Total = timeout
+	return r
+}
+
+func (r *BaseRequest) Proxy(host string, port int) HttpRequest {
+	r.proxy = NewProxy(host, port)
+	return r
+}
+
+func (r *BaseRequest) WithContext(ctx context.Context) HttpRequest {
+	r.ctx = ctx
+	return r
+}
+
+func (r *BaseRequest) DownloadMonitor(monitor ProgressMonitor) HttpRequest {
+	r.downloadMonitor = monitor
+	return r
+}
+
+func (r *BaseRequest) AsString() StringHttpResponse {
+	return r.execute(r, r.stringResponse)
+}
+
+func (r *BaseRequest) AsBytes() BytesHttpResponse {
+	return r.execute(r, r.bytesResponse)
+}
+
+func (r *BaseRequest) AsJson() JsonHttpResponse {
+	return r.execute(r, r.jsonResponse)
+}
+
+func (r *BaseRequest) AsObject() HttpResponse {
+	return r.AsObjectInto(nil)
+}
+
+func (r *BaseRequest) AsObjectInto(target interface{}) ObjectHttpResponse {
+	return r.execute(r.objectRequest(), r.objectResponse(target))
+}
+
+func (r *BaseRequest) AsFile(path string, copyOptions []CopyOption) FileHttpResponse {
+	return r.execute(r, r.fileResponse(path, copyOptions))
+}
+
+func (r *BaseRequest) AsPaged(mappingFunction PageMapper, linkExtractor LinkExtractor) PagedList {
+	return r.AsPagedWithMaxPages(mappingFunction, linkExtractor, DEFAULT_MAX_PAGES)
+}
+
+func (r *BaseRequest) AsPagedWithMaxPages(mappingFunction PageMapper, linkExtractor LinkExtractor, maxPages int) PagedList {
+	return followPages(r, r.retarget, mappingFunction, linkExtractor, maxPages)
+}
+
+func (r *BaseRequest) Pages(mappingFunction PageMapper, linkExtractor LinkExtractor) iter.Seq2[interface{}, error] {
+	return r.PagesWithOptions(mappingFunction, linkExtractor, PageOptions{MaxPages: DEFAULT_MAX_PAGES})
+}
+
+func (r *BaseRequest) PagesWithOptions(mappingFunction PageMapper, linkExtractor LinkExtractor, options PageOptions) iter.Seq2[interface{}, error] {
+	return iteratePages(r, r.retarget, mappingFunction, linkExtractor, options)
+}
+
+func (r *BaseRequest) AsEmpty() HttpResponse {
+	return r.execute(r, r.emptyResponse)
+}
+
+func (r *BaseRequest) AsStringAsync(ctx context.Context) *Future {
+	return r.AsStringAsyncWithCallback(ctx, nil)
+}
+
+func (r *BaseRequest) AsStringAsyncWithCallback(ctx context.Context, callback Callback) *Future {
+	return r.submit(ctx, r, r.stringResponse, callback)
+}
+
+func (r *BaseRequest) AsBytesAsync(ctx context.Context) *Future {
+	return r.AsBytesAsyncWithCallback(ctx, nil)
+}
+
+func (r *BaseRequest) AsBytesAsyncWithCallback(ctx context.Context, callback Callback) *Future {
+	return r.submit(ctx, r, r.bytesResponse, callback)
+}
+
+func (r *BaseRequest) AsJsonAsync(ctx context.Context) *Future {
+	return r.AsJsonAsyncWithCallback(ctx, nil)
+}
+
+func (r *BaseRequest) AsJsonAsyncWithCallback(ctx context.Context, callback Callback) *Future {
+	return r.submit(ctx, r, r.jsonResponse, callback)
+}
+
+func (r *BaseRequest) AsObjectAsync(ctx context.Context) *Future {
+	return r.AsObjectAsyncWithCallback(ctx, nil)
+}
+
+func (r *BaseRequest) AsObjectAsyncWithCallback(ctx context.Context, callback Callback) *Future {
+	return r.submit(ctx, r.objectRequest(), r.objectResponse(nil), callback)
+}
+
+func (r *BaseRequest) AsFileAsync(ctx context.Context, path string, copyOptions []CopyOption) *Future {
+	return r.submit(ctx, r, r.fileResponse(path, copyOptions), nil)
+}
+
+func (r *BaseRequest) AsEmptyAsync(ctx context.Context) *Future {
+	return r.AsEmptyAsyncWithCallback(ctx, nil)
+}
+
+func (r *BaseRequest) AsEmptyAsyncWithCallback(ctx context.Context, callback Callback) *Future {
+	return r.submit(ctx, r, r.emptyResponse, callback)
+}
+
+func (r *BaseRequest) ThenConsume(consumer RawResponseConsumer) error {
+	return r.consume(r.GetContext(), consumer)
+}
+
+func (r *BaseRequest) ThenConsumeAsync(ctx context.Context, consumer RawResponseConsumer) *Future {
+	return r.config.GetAsyncExecutor().Submit(ctx, func(ctx context.Context) (HttpResponse, error) {
+		return nil, r.consume(ctx, consumer)
+	}, nil)
+}
+
+func (r *BaseRequest) getHttpMethod() HttpMethod {
+	return r.method
+}
+
+/**
+ * @return the url with the route params replaced, resolved against Config.DefaultBaseUrl and with the query params appended.
+ * While AsPaged or Pages follow the links, the url of the current page
+ */
+func (r *BaseRequest) GetUrl() string {
+	if r.target != "" {
+		return r.target
+	}
+	return r.buildUrl()
+}
+
+func (r *BaseRequest) GetHeaders() Headers {
+	return r.headers
+}
+
+func (r *BaseRequest) getBody() Body {
+	return r.body
+}
+
+func (r *BaseRequest) GetSocketTimeout() int {
+	return int(r.timeouts.IdleRead.Milliseconds())
+}
+
+func (r *BaseRequest) GetConnectTimeout() int {
+	return int(r.timeouts.Dial.Milliseconds())
+}
+
+func (r *BaseRequest) GetTimeouts() Timeouts {
+	return r.timeouts
+}
+
+func (r *BaseRequest) GetCompressRequest() (bool, bool) {
+	if r.compress == nil {
+		return false, false
+	}
+	return *r.compress, true
+}
+
+func (r *BaseRequest) GetDecompressResponse() (bool, bool) {
+	if r.decompress == nil {
+		return false, false
+	}
+	return *r.decompress, true
+}
+
+func (r *BaseRequest) GetExpectedStatus() []int {
+	return r.expected
+}
+
+func (r *BaseRequest) GetAllowedHopByHop() []string {
+	return r.allowedHopByHop
+}
+
+func (r *BaseRequest) GetProxy() Proxy {
+	return r.proxy
+}
+
+func (r *BaseRequest) GetContext() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+/**
+ * @return a summary with the url as it was given as the raw path and the size of the body before compression
+ */
+func (r *BaseRequest) ToSummary() HttpRequestSummary {
+	var size int64
+	if r.body != nil {
+		if content, err := r.body.GetContent(); err == nil {
+			size = int64(len(content))
+		}
+	}
+	return NewRequestSummary(r, r.url, size)
+}
+
+func (r *BaseRequest) GetCreationTime() time.Time {
+	return r.creationTime
+}
+
+func (r *BaseRequest) buildUrl() string {
+	target := r.url
+	for name, value := range r.routeParams {
+		target = strings.ReplaceAll(target, "{"+name+"}", url.PathEscape(value))
+	}
+	if base := r.config.DefaultBaseUrl; base != "" && !strings.Contains(target, "://") {
+		target = strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(target, "/")
+	}
+	if len(r.query) == 0 {
+		return target
+	}
+	var query strings.Builder
+	for _, param := range r.query {
+		if query.Len() > 0 {
+			query.WriteByte('&')
+		}
+		query.WriteString(url.QueryEscape(param.name) + "=" + url.QueryEscape(param.value))
+	}
+	if strings.Contains(target, "?") {
+		return target + "&" + query.String()
+	}
+	return target + "?" + query.String()
+}
+
+// points the request at a page of AsPaged or Pages, the url it was built with clears the override
+func (r *BaseRequest) retarget(url string) {
+	if url == r.buildUrl() {
+		r.target = ""
+		return
+	}
+	r.target = url
+}
+
+/**
+ * The request AsObject sends: this one, or a copy with the Accept header of Config.Mappers when no Accept was set.
+ * Only AsObject can decode what the registry lists, the other As* methods send no Accept unless the caller set one
+ */
+func (r *BaseRequest) objectRequest() HttpRequest {
+	if r.objectMapper != nil || r.headers.ContainsKey(ACCEPT) {
+		return r
+	}
+	var request = *r
+	negotiateAccept(&request.headers, r.config.Mappers)
+	return &request
+}
+
+/**
+ * Send the request and turn a failure without a response into a response with status 0,
+ * and a StatusError into the parsing error of the response
+ */
+func (r *BaseRequest) execute(request HttpRequest, transformer RawResponseToHttpResponseTransformer) HttpResponse {
+	response, err := r.client.RequestWithContext(r.GetContext(), request, transformer)
+	if err == nil {
+		return response
+	}
+	failed, ok := response.(*BaseResponse)
+	if !ok {
+		return newFailedResponse(r.config, err)
+	}
+	if failed.parsingErr == nil {
+		failed.parsingErr = err
+	}
+	return failed
+}
+
+// run the request on the async executor of the config
+func (r *BaseRequest) submit(ctx context.Context, request HttpRequest, transformer RawResponseToHttpResponseTransformer, callback Callback) *Future {
+	return r.config.GetAsyncExecutor().Submit(ctx, func(ctx context.Context) (HttpResponse, error) {
+		return r.client.RequestWithContext(ctx, request, transformer)
+	}, callback)
+}
+
+func (r *BaseRequest) consume(ctx context.Context, consumer RawResponseConsumer) error {
+	var consumed error
+	_, err := r.client.RequestWithContext(ctx, r, func(raw RawResponse) HttpResponse {
+		r.withResponseEncoding(raw)
+		consumed = consumeRawResponse(raw, consumer)
+		return NewBaseResponse(raw, nil, consumed, r.objectMapper)
+	})
+	if consumed != nil {
+		return consumed
+	}
+	return err
+}
+
+func (r *BaseRequest) withResponseEncoding(raw RawResponse) {
+	if http, ok := raw.(*HttpRawResponse); ok && r.responseEncoding != "" {
+		http.WithResponseEncoding(r.responseEncoding)
+	}
+}
+
+// the error which cut reading the body short, if any
+func readError(raw RawResponse) error {
+	if http, ok := raw.(*HttpRawResponse); ok {
+		return http.GetReadError()
+	}
+	return nil
+}
+
+func (r *BaseRequest) stringResponse(raw RawResponse) HttpResponse {
+	r.withResponseEncoding(raw)
+	body := raw.GetContentAsString()
+	return NewBaseResponse(raw, body, readError(raw), r.objectMapper)
+}
+
+func (r *BaseRequest) bytesResponse(raw RawResponse) HttpResponse {
+	body := raw.GetContentAsBytes()
+	return NewBaseResponse(raw, body, readError(raw), r.objectMapper)
+}
+
+func (r *BaseRequest) jsonResponse(raw RawResponse) HttpResponse {
+	r.withResponseEncoding(raw)
+	text := raw.GetContentAsString()
+	if err := readError(raw); err != nil {
+		return NewBaseResponse(raw, nil, err, r.objectMapper)
+	}
+	node, err := NewJsonNode(text)
+	if err != nil {
+		return NewBaseResponse(raw, nil, &BodyParseError{ContentType: raw.GetContentType(), OriginalBody: text, Cause: err}, r.objectMapper)
+	}
+	return NewBaseResponse(raw, node, nil, r.objectMapper)
+}
+
+/**
+ * @param target a pointer to decode into, the body of the response. nil decodes into an interface{}
+ */
+func (r *BaseRequest) objectResponse(target interface{}) RawResponseToHttpResponseTransformer {
+	return func(raw RawResponse) HttpResponse {
+		r.withResponseEncoding(raw)
+		var value interface{}
+		into := target
+		if into == nil {
+			into = &value
+		}
+		if !raw.HasContent() {
+			return NewBaseResponse(raw, target, readError(raw), r.objectMapper)
+		}
+		err := decodeBody(r.config, r.objectMapper, raw, into)
+		if err == nil {
+			err = readError(raw)
+		}
+		if target == nil {
+			return NewBaseResponse(raw, value, err, r.objectMapper)
+		}
+		return NewBaseResponse(raw, target, err, r.objectMapper)
+	}
+}
+
+func (r *BaseRequest) fileResponse(path string, copyOptions []CopyOption) RawResponseToHttpResponseTransformer {
+	return func(raw RawResponse) HttpResponse {
+		err := downloadFile(raw, path, copyOptions, r.downloadMonitor)
+		return NewBaseResponse(raw, path, err, r.objectMapper)
+	}
+}
+
+func (r *BaseRequest) emptyResponse(raw RawResponse) HttpResponse {
+	body := raw.GetContent()
+	io.Copy(io.Discard, body)
+	body.Close()
+	return NewBaseResponse(raw, nil, nil, r.objectMapper)
+}
+
+/**
+ * Write the body into a file
+ * @param raw the response
+ * @param path the file
+ * @param copyOptions REPLACE_EXISTING to overwrite the file, ATOMIC_MOVE to write a temporary file first
+ * @param monitor follows the download, may be nil
+ * @return the error of reading the body or writing the file
+ */
+func downloadFile(raw RawResponse, path string, copyOptions []CopyOption, monitor ProgressMonitor) error {
+	body := raw.GetContent()
+	defer body.Close()
+	replace, atomic := false, false
+	for _, option := range copyOptions {
+		replace = replace || option == REPLACE_EXISTING
+		atomic = atomic || option == ATOMIC_MOVE
+	}
+	if _, err := os.Stat(path); err == nil && !replace {
+		return &os.PathError{Op: "create", Path: path, Err: os.ErrExist}
+	}
+
+	var file *os.File
+	var err error
+	if atomic {
+		file, err = os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	} else {
+		file, err = os.Create(path)
+	}
+	if err != nil {
+		return err
+	}
+	var reader io.Reader = body
+	if monitor != nil {
+		total := int64(-1)
+		headers := raw.GetHeaders()
+		if length, ok := headers.GetContentLength(); ok {
+			total = length
+		}
+		reader = &monitoredReader{Reader: body, monitor: monitor, fileName: filepath.Base(path), total: total}
+	}
+	_, err = io.Copy(file, reader)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if atomic {
+		if err == nil {
+			err = os.Rename(file.Name(), path)
+		}
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}
+	return err
+}
+
+// reports the bytes read to a ProgressMonitor
+type monitoredReader struct {
+	io.Reader
+	monitor  ProgressMonitor
+	fileName string
+	total    int64
+	read     int64
+}
+
+func (m *monitoredReader) Read(p []byte) (int, error) {
+	n, err := m.Reader.Read(p)
+	if n > 0 {
+		m.read += int64(n)
+		m.monitor("", m.fileName, m.read, m.total)
+	}
+	return n, err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBaseRequestBuildsTheUrl(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL.RequestURI()
+	}))
+	defer server.Close()
+	config := NewDefaultConfig()
+	config.DefaultBaseUrl = server.URL + "/api/"
+	client := newTestClient(t, config)
+
+	request := client.Get("/users/{name}/items")
+	request.RouteParam("name", "a b/c").QueryString("q", "x&y").QueryStringWithValues("tag", []interface{}{1, 2})
+	response := request.AsEmpty()
+	if !response.IsSuccess() {
+		t.Fatal(response.GetParsingError())
+	}
+	want := "/api/users/a%20b%2Fc/items?q=x%26y&tag=1&tag=2"
+	if received != want {
+		t.Errorf("requested %q, want %q", received, want)
+	}
+	summary := request.ToSummary()
+	if summary.GetRawPath() != "/users/{name}/items" || summary.GetUrl() != server.URL+want {
+		t.Errorf("summary %s %s", summary.GetRawPath(), summary.GetUrl())
+	}
+}
+
+func TestBaseRequestSendsTheRegistryAcceptOnlyForAsObject(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(CONTENT_TYPE, string(APPLICATION_JSON))
+		fmt.Fprintf(w, "%q", r.Header.Get(ACCEPT))
+	}))
+	defer server.Close()
+	config := NewDefaultConfig()
+	client := newTestClient(t, config)
+
+	if body := client.Get(server.URL).AsString().GetBody(); body != `""` {
+		t.Errorf("AsString sent Accept %s", body)
+	}
+	if body := client.Get(server.URL).AsObject().GetBody(); body != config.Mappers.Accept() {
+		t.Errorf("AsObject sent Accept %v, want %q", body, config.Mappers.Accept())
+	}
+	if body := client.Get(server.URL).Accept("application/json").AsObject().GetBody(); body != "application/json" {
+		t.Errorf("AsObject replaced the Accept of the caller: %v", body)
+	}
+}
+
+func TestBaseRequestDecodesObjectsByContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(CONTENT_TYPE, "application/xml; charset=utf-8")
+		io.WriteString(w, `<item><name>one</name></item>`)
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	var item struct {
+		Name string `xml:"name" json:"name"`
+	}
+	response := client.Get(server.URL).AsObjectInto(&item)
+	if !response.IsSuccess() || item.Name != "one" {
+		t.Fatalf("decoded %+v: %v", item, response.GetParsingError())
+	}
+	if response.GetBody() != &item {
+		t.Errorf("body is %v, want the target", response.GetBody())
+	}
+
+	var number int
+	response = client.Get(server.URL).WithObjectMapper(NewJsonObjectMapper()).AsObjectInto(&number)
+	var parseErr *BodyParseError
+	if !errors.As(response.GetParsingError(), &parseErr) || parseErr.OriginalBody != `<item><name>one</name></item>` {
+		t.Errorf("the mapper of the request was not used: %v", response.GetParsingError())
+	}
+}
+
+func TestBaseRequestReportsFailuresWithoutResponse(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	response := client.Get(url).AsString()
+	var connect *ConnectError
+	if response.GetStatus() != 0 || response.IsSuccess() || !errors.As(response.GetParsingError(), &connect) {
+		t.Errorf("status %d, error %v", response.GetStatus(), response.GetParsingError())
+	}
+}
+
+func TestBaseRequestKeepsTheResponseOfUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		io.WriteString(w, "short and stout")
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	response := client.Get(server.URL).ExpectStatus(http.StatusOK).AsString()
+	var status *StatusError
+	if response.GetStatus() != http.StatusTeapot || response.GetBody() != "short and stout" || !errors.As(response.GetParsingError(), &status) {
+		t.Errorf("status %d, body %v, error %v", response.GetStatus(), response.GetBody(), response.GetParsingError())
+	}
+}
+
+func TestBaseRequestWritesFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "file contents")
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+	path := filepath.Join(t.TempDir(), "download.txt")
+
+	var written, total int64
+	response := client.Get(server.URL).DownloadMonitor(func(field string, fileName string, bytesWritten int64, totalBytes int64) {
+		written, total = bytesWritten, totalBytes
+	}).AsFile(path, nil)
+	if !response.IsSuccess() || response.GetBody() != path {
+		t.Fatal(response.GetParsingError())
+	}
+	if content, _ := os.ReadFile(path); string(content) != "file contents" {
+		t.Errorf("file holds %q", content)
+	}
+	if written != 13 || total != 13 {
+		t.Errorf("monitor saw %d of %d bytes", written, total)
+	}
+
+	if response := client.Get(server.URL).AsFile(path, nil); !errors.Is(response.GetParsingError(), os.ErrExist) {
+		t.Errorf("an existing file was overwritten: %v", response.GetParsingError())
+	}
+	response = client.Get(server.URL).AsFile(path, []CopyOption{REPLACE_EXISTING, ATOMIC_MOVE})
+	if !response.IsSuccess() {
+		t.Fatal(response.GetParsingError())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
+
+func TestBaseRequestThenConsume(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, strings.Repeat("x", 10000))
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	var read int64
+	err := client.Get(server.URL).ThenConsume(func(raw RawResponse) error {
+		var err error
+		read, err = io.Copy(io.Discard, raw.GetContent())
+		return err
+	})
+	if err != nil || read != 10000 {
+		t.Errorf("consumed %d bytes: %v", read, err)
+	}
+
+	failure := errors.New("consumer failed")
+	if err := client.Get(server.URL).ThenConsume(func(raw RawResponse) error { return failure }); err != failure {
+		t.Errorf("got %v, want the error of the consumer", err)
+	}
+	future := client.Get(server.URL).ThenConsumeAsync(context.Background(), func(raw RawResponse) error { return failure })
+	if _, err := future.Get(context.Background()); err != failure {
+		t.Errorf("async got %v, want the error of the consumer", err)
+	}
+}
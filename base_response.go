@@ -1,4 +1,143 @@
 package main
 
+import (
+	"net/http"
+	"time"
+)
+
+/**
+ * The HttpResponse built by the As* methods of BaseRequest
+ */
 type BaseResponse struct {
+	status     int
+	statusText string
+	headers    Headers
+	body       interface{}
+	parsingErr error
+	config     *Config
+	// the ObjectMapper set on the request, may be nil
+	mapper ObjectMapper
+}
+
+/**
+ * @param raw the raw response
+ * @param body the body mapped from the raw response
+ * @param parsingErr the error of mapping the body, nil when it was mapped
+ * @param mapper the ObjectMapper of the request, may be nil
+ * @return the response
+ */
+func NewBaseResponse(raw RawResponse, body interface{}, parsingErr error, mapper ObjectMapper) *BaseResponse {
+	var response = new(BaseResponse)
+	response.status = raw.GetStatus()
+	response.statusText = raw.GetStatusText()
+	response.headers = raw.GetHeaders()
+	response.body = body
+	response.parsingErr = parsingErr
+	response.config = raw.GetConfig()
+	response.mapper = mapper
+	return response
+}
+
+/**
+ * A response for a request which failed without one, with status 0 and the error as the parsing error
+ * @param config the current config
+ * @param err the error
+ * @return the response
+ */
+func newFailedResponse(config *Config, err error) *BaseResponse {
+	var response = new(BaseResponse)
+	response.headers = *NewHeaders()
+	response.parsingErr = err
+	response.config = config
+	return response
+}
+
+func (r *BaseResponse) GetStatus() int {
+	return r.status
+}
+
+func (r *BaseResponse) GetStatusText() string {
+	return r.statusText
+}
+
+func (r *BaseResponse) GetHeaders() Headers {
+	return r.headers
+}
+
+func (r *BaseResponse) GetBody() interface{} {
+	return r.body
+}
+
+func (r *BaseResponse) GetParsingError() error {
+	return r.parsingErr
+}
+
+func (r *BaseResponse) MapBody(f MapBody) interface{} {
+	return f(r.body)
+}
+
+func (r *BaseResponse) Map(f MapHttpResponse) HttpResponse {
+	var mapped = new(BaseResponse)
+	*mapped = *r
+	mapped.body = f(r.body)
+	return mapped
+}
+
+func (r *BaseResponse) IfSuccess(consumer HttpResponseConsumer) HttpResponse {
+	if r.IsSuccess() {
+		var response HttpResponse = r
+		consumer(&response)
+	}
+	return r
+}
+
+func (r *BaseResponse) IfFailure(consumer HttpResponseConsumer) HttpResponse {
+	if !r.IsSuccess() {
+		var response HttpResponse = r
+		consumer(&response)
+	}
+	return r
+}
+
+func (r *BaseResponse) IfFailureWithError(target interface{}, consumer HttpResponseErrorConsumer) HttpResponse {
+	if !r.IsSuccess() {
+		consumer(r, r.MapError(target))
+	}
+	return r
+}
+
+func (r *BaseResponse) IsSuccess() bool {
+	return StatusCode(r.status).IsSuccess() && r.parsingErr == nil
+}
+
+func (r *BaseResponse) MapError(target interface{}) error {
+	return mapErrorBody(r, selectErrorMapper(r.config, r.mapper, r.headers.GetFirst(CONTENT_TYPE)), target)
+}
+
+/**
+ * @return the cookies of the Set-Cookie headers, invalid ones are left out
+ */
+func (r *BaseResponse) GetCookies() Cookies {
+	header := make(http.Header)
+	for _, value := range r.headers.Get(SET_COOKIE) {
+		header.Add(SET_COOKIE, value)
+	}
+	received := (&http.Response{Header: header}).Cookies()
+	cookies := make(Cookies, 0, len(received))
+	for _, c := range received {
+		var cookie = Cookie{Name: c.Name, value: c.Value, domain: c.Domain, path: c.Path, httpOnly: c.HttpOnly, maxAge: c.MaxAge, secure: c.Secure}
+		if !c.Expires.IsZero() {
+			cookie.expires = c.Expires.In(time.UTC)
+		}
+		switch c.SameSite {
+		case http.SameSiteStrictMode:
+			cookie.SameSite = "Strict"
+		case http.SameSiteLaxMode:
+			cookie.SameSite = "Lax"
+		case http.SameSiteNoneMode:
+			cookie.SameSite = "None"
+		}
+		cookies = append(cookies, cookie)
+	}
+	return cookies
 }
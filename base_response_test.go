@@ -0,0 +1,64 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBaseResponseParsesCookies(t *testing.T) {
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(SET_COOKIE, "session=abc; Path=/; HttpOnly; Secure; SameSite=Strict; Expires="+expires.Format(http.TimeFormat))
+		w.Header().Add(SET_COOKIE, "theme=dark; Max-Age=60")
+		w.Header().Add(SET_COOKIE, "no value pair")
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	cookies := client.Get(server.URL).AsEmpty().GetCookies()
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies: %+v", len(cookies), cookies)
+	}
+	session := cookies[0]
+	if session.Name != "session" || session.value != "abc" || session.path != "/" || !session.httpOnly || !session.secure ||
+		session.SameSite != "Strict" || !session.expires.Equal(expires) {
+		t.Errorf("session cookie %+v", session)
+	}
+	if theme := cookies[1]; theme.Name != "theme" || theme.value != "dark" || theme.maxAge != 60 {
+		t.Errorf("theme cookie %+v", theme)
+	}
+}
+
+func TestBaseResponseConsumers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		io.WriteString(w, "body")
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	succeeded, failed := 0, 0
+	response := client.Get(server.URL).AsString().
+		IfSuccess(func(response *HttpResponse) { succeeded++ }).
+		IfFailure(func(response *HttpResponse) { failed++ })
+	if succeeded != 1 || failed != 0 {
+		t.Errorf("success: IfSuccess %d, IfFailure %d", succeeded, failed)
+	}
+	mapped := response.Map(func(body interface{}) interface{} { return strings.ToUpper(body.(string)) })
+	if mapped.GetBody() != "BODY" || response.GetBody() != "body" || mapped.GetStatus() != http.StatusOK {
+		t.Errorf("mapped %v from %v", mapped.GetBody(), response.GetBody())
+	}
+
+	client.Get(server.URL + "/missing").AsString().
+		IfSuccess(func(response *HttpResponse) { succeeded++ }).
+		IfFailure(func(response *HttpResponse) { failed++ })
+	if succeeded != 1 || failed != 1 {
+		t.Errorf("failure: IfSuccess %d, IfFailure %d", succeeded, failed)
+	}
+}
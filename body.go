@@ -0,0 +1,55 @@
+package main
+
+/**
+ * The body of a request
+ */
+type Body interface {
+	IsMultiPart() bool
+	IsEntityBody() bool
+
+	/**
+	 * @return the encoded body as it is sent, before compression
+	 */
+	GetContent() ([]byte, error)
+
+	/**
+	 * @return the Content-Type of the body, "" to leave the header as set on the request
+	 */
+	GetContentType() string
+}
+
+/**
+ * A body of raw bytes
+ */
+type BytesBody struct {
+	content     []byte
+	contentType string
+}
+
+/**
+ * @param content the body
+ * @param contentType the Content-Type, may be empty
+ * @return the body
+ */
+func NewBytesBody(content []byte, contentType string) *BytesBody {
+	var body = new(BytesBody)
+	body.content = content
+	body.contentType = contentType
+	return body
+}
+
+func (b *BytesBody) IsMultiPart() bool {
+	return false
+}
+
+func (b *BytesBody) IsEntityBody() bool {
+	return true
+}
+
+func (b *BytesBody) GetContent() ([]byte, error) {
+	return b.content, nil
+}
+
+func (b *BytesBody) GetContentType() string {
+	return b.contentType
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const DEFAULT_CERTIFICATE_RELOAD_INTERVAL = time.Minute
+
+/**
+ * Supplies the client certificate. Returning a nil certificate and a nil error keeps the current one.
+ */
+type CertificateProvider func() (*tls.Certificate, error)
+
+/**
+ * Keeps the client certificate of TlsConfig up to date while the Client is running.
+ * The certificate is fetched again every interval and used for new connections;
+ * connections which are already open, and the requests on them, keep the certificate they were made with.
+ * When reloading fails the previous certificate stays in use and the error goes to the OnReloadError hook.
+ */
+type CertificateReloader struct {
+	provider CertificateProvider
+	interval time.Duration
+	// called with every failed reload, may be nil
+	OnReloadError func(err error)
+
+	lock       sync.RWMutex
+	reloadLock sync.Mutex
+	current    *tls.Certificate
+	stop       chan struct{}
+	startOnce  sync.Once
+	stopOnce   sync.Once
+}
+
+/**
+ * Create a reloader around a provider. The provider is called once right away
+ * and must return a certificate. Call Start to begin reloading.
+ * @param provider supplies the certificate
+ * @param interval the time between reloads, DEFAULT_CERTIFICATE_RELOAD_INTERVAL when <= 0
+ * @return the reloader
+ */
+func NewCertificateReloader(provider CertificateProvider, interval time.Duration) (*CertificateReloader, error) {
+	if interval <= 0 {
+		interval = DEFAULT_CERTIFICATE_RELOAD_INTERVAL
+	}
+	certificate, err := provider()
+	if err != nil {
+		return nil, err
+	}
+	if certificate == nil {
+		return nil, errors.New("fiftyrest: certificate provider returned no initial certificate")
+	}
+	var reloader = new(CertificateReloader)
+	reloader.provider = provider
+	reloader.interval = interval
+	reloader.current = certificate
+	reloader.stop = make(chan struct{})
+	return reloader, nil
+}
+
+/**
+ * Create a reloader for a PEM certificate and key file. The files are read again
+ * when the modification time of either of them changes.
+ * @param certFile the PEM file of the certificate chain
+ * @param keyFile the PEM file of the private key
+ * @param interval the time between checks of the files, DEFAULT_CERTIFICATE_RELOAD_INTERVAL when <= 0
+ * @return the reloader
+ */
+func NewFileCertificateReloader(certFile string, keyFile string, interval time.Duration) (*CertificateReloader, error) {
+	var certModified, keyModified time.Time
+	provider := func() (*tls.Certificate, error) {
+		certInfo, err := os.Stat(certFile)
+		if err != nil {
+			return nil, fmt.Errorf("fiftyrest: checking client certificate: %w", err)
+		}
+		keyInfo, err := os.Stat(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("fiftyrest: checking client key: %w", err)
+		}
+		if certInfo.ModTime().Equal(certModified) && keyInfo.ModTime().Equal(keyModified) {
+			return nil, nil
+		}
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			// the files may be caught half way through a rotation, try again next time
+			return nil, fmt.Errorf("fiftyrest: loading client certificate: %w", err)
+		}
+		certModified, keyModified = certInfo.ModTime(), keyInfo.ModTime()
+		return &certificate, nil
+	}
+	return NewCertificateReloader(provider, interval)
+}
+
+/**
+ * Start reloading in the background. Calling it again has no effect.
+ * @return this reloader
+ */
+func (r *CertificateReloader) Start() *CertificateReloader {
+	r.startOnce.Do(func() {
+		go r.run()
+	})
+	return r
+}
+
+func (r *CertificateReloader) run() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
+
+/**
+ * Fetch the certificate now instead of waiting for the next interval
+ * @return the error of the provider, which was also passed to OnReloadError
+ */
+func (r *CertificateReloader) Reload() error {
+	r.reloadLock.Lock()
+	defer r.reloadLock.Unlock()
+	certificate, err := r.provider()
+	if err != nil {
+		if r.OnReloadError != nil {
+			r.OnReloadError(err)
+		}
+		return err
+	}
+	if certificate != nil {
+		r.lock.Lock()
+		r.current = certificate
+		r.lock.Unlock()
+	}
+	return nil
+}
+
+/**
+ * Stop reloading. The last certificate stays in use.
+ */
+func (r *CertificateReloader) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+}
+
+/**
+ * @return the certificate new connections are made with
+ */
+func (r *CertificateReloader) GetCertificate() *tls.Certificate {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return r.current
+}
+
+/**
+ * Meant for tls.Config.GetClientCertificate
+ */
+func (r *CertificateReloader) GetClientCertificate(info *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return r.GetCertificate(), nil
+}
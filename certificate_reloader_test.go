@@ -0,0 +1,169 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self signed certificate for commonName and its key as PEM files
+func writeKeyPair(t *testing.T, certFile string, keyFile string, commonName string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func commonNameOf(t *testing.T, certificate *tls.Certificate) string {
+	parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.Subject.CommonName
+}
+
+func TestCertificateReloaderNeedsAnInitialCertificate(t *testing.T) {
+	if _, err := NewCertificateReloader(func() (*tls.Certificate, error) { return nil, nil }, 0); err == nil {
+		t.Error("a provider without a certificate was accepted")
+	}
+	failure := errors.New("no certificate yet")
+	if _, err := NewCertificateReloader(func() (*tls.Certificate, error) { return nil, failure }, 0); !errors.Is(err, failure) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCertificateReloaderReload(t *testing.T) {
+	first, second := new(tls.Certificate), new(tls.Certificate)
+	var next *tls.Certificate = first
+	var nextErr error
+	reloader, err := NewCertificateReloader(func() (*tls.Certificate, error) { return next, nextErr }, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reported []error
+	reloader.OnReloadError = func(err error) { reported = append(reported, err) }
+
+	next = second
+	if err := reloader.Reload(); err != nil || reloader.GetCertificate() != second {
+		t.Fatalf("rotation: %v", err)
+	}
+	next = nil
+	if err := reloader.Reload(); err != nil || reloader.GetCertificate() != second {
+		t.Errorf("a nil certificate replaced the current one: %v", err)
+	}
+	nextErr = errors.New("rotation half done")
+	if err := reloader.Reload(); !errors.Is(err, nextErr) || reloader.GetCertificate() != second {
+		t.Errorf("a failed reload replaced the current one: %v", err)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], nextErr) {
+		t.Errorf("OnReloadError got %v", reported)
+	}
+	if certificate, err := reloader.GetClientCertificate(nil); certificate != second || err != nil {
+		t.Errorf("GetClientCertificate returned %v, %v", certificate, err)
+	}
+}
+
+func TestCertificateReloaderStartReloadsUntilStopped(t *testing.T) {
+	var lock sync.Mutex
+	calls := 0
+	reloader, err := NewCertificateReloader(func() (*tls.Certificate, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		return new(tls.Certificate), nil
+	}, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := reloader.GetCertificate()
+	reloader.Start().Start()
+	deadline := time.Now().Add(5 * time.Second)
+	for reloader.GetCertificate() == initial {
+		if time.Now().After(deadline) {
+			t.Fatal("the certificate was never reloaded")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	reloader.Stop()
+	reloader.Stop()
+	time.Sleep(10 * time.Millisecond)
+	lock.Lock()
+	stopped := calls
+	lock.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	lock.Lock()
+	defer lock.Unlock()
+	if calls != stopped {
+		t.Errorf("reloading went on after Stop: %d calls, then %d", stopped, calls)
+	}
+}
+
+func TestFileCertificateReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	writeKeyPair(t, certFile, keyFile, "first")
+	reloader, err := NewFileCertificateReloader(certFile, keyFile, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name := commonNameOf(t, reloader.GetCertificate()); name != "first" {
+		t.Fatalf("loaded %q", name)
+	}
+
+	unchanged := reloader.GetCertificate()
+	if err := reloader.Reload(); err != nil || reloader.GetCertificate() != unchanged {
+		t.Errorf("unchanged files were read again: %v", err)
+	}
+
+	writeKeyPair(t, certFile, keyFile, "second")
+	later := time.Now().Add(time.Minute)
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if name := commonNameOf(t, reloader.GetCertificate()); name != "second" {
+		t.Errorf("reloaded %q", name)
+	}
+
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := reloader.Reload(); err == nil || commonNameOf(t, reloader.GetCertificate()) != "second" {
+		t.Errorf("a missing key file: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const DEFAULT_CHARSET = "UTF-8"
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+/**
+ * Sniff a byte order mark at the start of the content
+ * @param content the body
+ * @return the charset announced by the mark and the length of the mark, or "" and 0 when there is none
+ */
+func sniffBOM(content []byte) (string, int) {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return "UTF-8", len(bomUTF8)
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return "UTF-16BE", len(bomUTF16BE)
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return "UTF-16LE", len(bomUTF16LE)
+	}
+	return "", 0
+}
+
+/**
+ * @param contentType the value of a Content-Type header
+ * @return the charset param of the content type, or "" when there is none
+ */
+func charsetFromContentType(contentType string) string {
+	media, err := ParseContentType(contentType)
+	if err != nil {
+		return ""
+	}
+	return media.GetCharset()
+}
+
+/**
+ * Work out the charset of a body. A byte order mark wins, then the charset param of the Content-Type,
+ * then the encoding set on the request with ResponseEncoding, then the config default and finally UTF-8.
+ * Names which are not known are skipped.
+ * @param content the body
+ * @param contentType the value of the Content-Type header
+ * @param requestEncoding the encoding set on the request, may be empty
+ * @param defaultEncoding the default of the config, may be empty
+ * @return the charset name
+ */
+func detectCharset(content []byte, contentType string, requestEncoding string, defaultEncoding string) string {
+	if charset, _ := sniffBOM(content); charset != "" {
+		return charset
+	}
+	for _, candidate := range []string{charsetFromContentType(contentType), requestEncoding, defaultEncoding} {
+		if candidate != "" && lookupCharset(candidate) != nil {
+			return candidate
+		}
+	}
+	return DEFAULT_CHARSET
+}
+
+func lookupCharset(charset string) encoding.Encoding {
+	name := strings.ToLower(strings.TrimSpace(charset))
+	switch name {
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
+	case "utf-16":
+		// RFC 2781 section 4.3, big endian unless a byte order mark says otherwise.
+		// Not ExpectBOM, which fails on the bodies without a mark this is used for
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil
+	}
+	return enc
+}
+
+/**
+ * Decode a body into a string. A byte order mark is removed. ISO-8859-x, Windows-125x, UTF-16
+ * and the other WHATWG encodings are transcoded, unknown charsets are treated as UTF-8.
+ * Bytes which are invalid in the charset become U+FFFD.
+ * @param content the body
+ * @param charset the charset name
+ * @return the decoded string
+ */
+func DecodeContent(content []byte, charset string) string {
+	if bomCharset, length := sniffBOM(content); length > 0 {
+		content = content[length:]
+		charset = bomCharset
+	}
+	enc := lookupCharset(charset)
+	if enc == nil || enc == encoding.Nop {
+		return string(content)
+	}
+	decoded, err := enc.NewDecoder().Bytes(content)
+	if err != nil {
+		return string(content)
+	}
+	return string(decoded)
+}
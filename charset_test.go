@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDetectCharsetPrecedence(t *testing.T) {
+	latin := []byte("caf\xe9")
+	cases := []struct {
+		content                                  []byte
+		contentType, requestEncoding, defaultEnc string
+		want                                     string
+	}{
+		{append([]byte{0xEF, 0xBB, 0xBF}, 'x'), "text/plain; charset=iso-8859-1", "", "", "UTF-8"},
+		{latin, "text/plain; charset=ISO-8859-1", "windows-1252", "", "ISO-8859-1"},
+		{latin, "text/plain; charset=no-such-charset", "windows-1252", "", "windows-1252"},
+		{latin, "text/plain", "", "ISO-8859-2", "ISO-8859-2"},
+		{latin, "", "", "", DEFAULT_CHARSET},
+	}
+	for _, c := range cases {
+		if got := detectCharset(c.content, c.contentType, c.requestEncoding, c.defaultEnc); got != c.want {
+			t.Errorf("%q %q %q %q: got %s, want %s", c.content, c.contentType, c.requestEncoding, c.defaultEnc, got, c.want)
+		}
+	}
+}
+
+func TestDecodeContent(t *testing.T) {
+	cases := []struct {
+		content []byte
+		charset string
+		want    string
+	}{
+		{[]byte("caf\xe9"), "ISO-8859-1", "café"},
+		{[]byte("\x80 5"), "windows-1252", "€ 5"},
+		{[]byte{0xFE, 0xFF, 0x00, 'h', 0x00, 'i'}, "ISO-8859-1", "hi"},
+		{[]byte{0xFF, 0xFE, 'h', 0x00, 'i', 0x00}, "", "hi"},
+		{[]byte{0x00, 'h', 0x00, 'i'}, "UTF-16", "hi"},
+		{[]byte{'h', 0x00, 'i', 0x00}, "UTF-16LE", "hi"},
+		{[]byte("\xEF\xBB\xBFcafé"), "", "café"},
+		{[]byte("café"), "unknown-charset", "café"},
+	}
+	for _, c := range cases {
+		if got := DecodeContent(c.content, c.charset); got != c.want {
+			t.Errorf("%q as %s: got %q, want %q", c.content, c.charset, got, c.want)
+		}
+	}
+}
+
+func TestHttpRawResponseDecodesWithItsCharset(t *testing.T) {
+	var config = NewDefaultConfig()
+	config.SetDefaultResponseEncoding("windows-1252")
+	newRaw := func(contentType string) *HttpRawResponse {
+		raw, _ := newTrackedRawResponse("\xa3")
+		raw.response.Header = http.Header{}
+		raw.headers = *NewHeaders()
+		if contentType != "" {
+			raw.headers.Add(CONTENT_TYPE, contentType)
+		}
+		raw.config = config
+		return raw
+	}
+	if got := newRaw("").GetContentAsString(); got != "£" {
+		t.Errorf("config default not used: %q", got)
+	}
+	if got := newRaw("text/plain").WithResponseEncoding("ISO-8859-2").GetContentAsString(); got != "Ł" {
+		t.Errorf("ResponseEncoding not used: %q", got)
+	}
+	if got := newRaw("text/plain; charset=utf-8").GetContentAsString(); got != "�" {
+		t.Errorf("Content-Type charset not used: %q", got)
+	}
+}
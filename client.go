@@ -1,17 +1,45 @@
 package main
 
+import "context"
+
 type RawResponseToHttpResponseTransformer func(raw RawResponse) HttpResponse
 
 type Client interface {
 	GetClient() interface{}
 
-	Request(request HttpRequest, httpResponse RawResponseToHttpResponseTransformer)
+	/**
+	 * Execute the request with the context of the request, see RequestWithContext
+	 * @param request the request
+	 * @param httpResponse the transformer from the raw response
+	 * @return the response, or the error of sending it
+	 */
+	Request(request HttpRequest, httpResponse RawResponseToHttpResponseTransformer) (HttpResponse, error)
+
+	/**
+	 * Execute the request, aborting it when ctx is cancelled or reaches its deadline.
+	 * ctx takes precedence over the request's own context.
+	 * @param ctx the context
+	 * @param request the request
+	 * @param httpResponse the transformer from the raw response
+	 * @return the response, a CancelledError if ctx ended before a response was received,
+	 * or a StatusError with the response if its status was not expected, see HttpRequest.ExpectStatus and Config.ErrorOnFailureStatus
+	 */
+	RequestWithContext(ctx context.Context, request HttpRequest, httpResponse RawResponseToHttpResponseTransformer) (HttpResponse, error)
 
 	//  default <T> HttpResponse<T> request(HttpRequest request, Function<RawResponse, HttpResponse<T>> transformer, Class<?> resultType){
 	// 	 return request(request, transformer);
 	//  }
 
+	/**
+	 * Stop accepting requests, wait up to Config.ShutdownTimeout for the running ones and release
+	 * idle connections, the async executor and anything registered with Config.OnShutdown.
+	 * ClientLifecycle implements this for embedding.
+	 * @return an error if requests were still running at the deadline or a shutdown hook failed
+	 */
 	Close() error
 
+	/**
+	 * Close the client when the process receives SIGINT or SIGTERM
+	 */
 	RegisterShutdownHook()
 }
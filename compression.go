@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ENCODING_GZIP     = "gzip"
+	ENCODING_DEFLATE  = "deflate"
+	ENCODING_BROTLI   = "br"
+	ENCODING_ZSTD     = "zstd"
+	ENCODING_IDENTITY = "identity"
+
+	/** the Accept-Encoding sent when response decompression is on */
+	DEFAULT_ACCEPT_ENCODING = "gzip, deflate, br, zstd"
+)
+
+/**
+ * @param contentEncoding the value of a Content-Encoding header
+ * @return the codings in the order they were applied, without identity
+ */
+func parseContentEncoding(contentEncoding string) []string {
+	codings := make([]string, 0)
+	for _, coding := range strings.Split(contentEncoding, ",") {
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "" && coding != ENCODING_IDENTITY {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+/**
+ * @param contentEncoding the value of a Content-Encoding header
+ * @return true if the body is compressed and every coding can be decoded
+ */
+func canDecompress(contentEncoding string) bool {
+	codings := parseContentEncoding(contentEncoding)
+	if len(codings) == 0 {
+		return false
+	}
+	for _, coding := range codings {
+		switch coding {
+		case ENCODING_GZIP, "x-gzip", ENCODING_DEFLATE, ENCODING_BROTLI, ENCODING_ZSTD:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Wrap a body so reading it undoes the Content-Encoding. Codings are removed in the reverse
+ * of the order they were applied. Closing the returned reader closes the body.
+ * @param contentEncoding the value of the Content-Encoding header
+ * @param body the compressed body
+ * @return the decompressed body
+ */
+func decompressBody(contentEncoding string, body io.ReadCloser) (io.ReadCloser, error) {
+	codings := parseContentEncoding(contentEncoding)
+	var reader io.Reader = body
+	closers := make([]io.Closer, 0, len(codings))
+	fail := func(err error) (io.ReadCloser, error) {
+		// release the readers of the codings decoded so far, the body itself stays with the caller
+		(&decompressedBody{closers: closers}).Close()
+		return nil, err
+	}
+	for i := len(codings) - 1; i >= 0; i-- {
+		switch codings[i] {
+		case ENCODING_GZIP, "x-gzip":
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				return fail(fmt.Errorf("fiftyrest: invalid gzip body: %w", err))
+			}
+			closers = append(closers, gz)
+			reader = gz
+		case ENCODING_DEFLATE:
+			deflate, err := newDeflateReader(reader)
+			if err != nil {
+				return fail(fmt.Errorf("fiftyrest: invalid deflate body: %w", err))
+			}
+			closers = append(closers, deflate)
+			reader = deflate
+		case ENCODING_BROTLI:
+			reader = brotli.NewReader(reader)
+		case ENCODING_ZSTD:
+			zr, err := zstd.NewReader(reader)
+			if err != nil {
+				return fail(fmt.Errorf("fiftyrest: invalid zstd body: %w", err))
+			}
+			closers = append(closers, zr.IOReadCloser())
+			reader = zr
+		default:
+			return fail(fmt.Errorf("fiftyrest: unsupported content encoding %q", codings[i]))
+		}
+	}
+	return &decompressedBody{Reader: reader, closers: append(closers, body)}, nil
+}
+
+// "deflate" is meant to be zlib wrapped (RFC 9110 8.4.1.2) but some servers send raw deflate, so sniff the zlib header.
+func newDeflateReader(reader io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(reader)
+	header, err := buffered.Peek(2)
+	if err == nil && header[0]&0x0F == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
+
+type decompressedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d *decompressedBody) Close() error {
+	var first error
+	for _, closer := range d.closers {
+		if err := closer.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+/**
+ * Ask for compressed responses unless the caller already chose an Accept-Encoding
+ * @param headers the request headers
+ * @param decompress if response decompression is on for the request
+ */
+func negotiateAcceptEncoding(headers *Headers, decompress bool) {
+	if decompress && !headers.ContainsKey(ACCEPT_ENCODING) {
+		headers.Add(ACCEPT_ENCODING, DEFAULT_ACCEPT_ENCODING)
+	}
+}
+
+// a body which fails every read, used when the decompressor could not be created
+type errorBody struct {
+	err  error
+	body io.Closer
+}
+
+func (e errorBody) Read(p []byte) (int, error) {
+	return 0, e.err
+}
+
+func (e errorBody) Close() error {
+	return e.body.Close()
+}
+
+/**
+ * Compress a request body
+ * @param codec one of ENCODING_GZIP, ENCODING_DEFLATE, ENCODING_BROTLI or ENCODING_ZSTD
+ * @param content the body
+ * @return the compressed body
+ */
+func compressBody(codec string, content []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	switch strings.ToLower(codec) {
+	case ENCODING_GZIP:
+		writer = gzip.NewWriter(&buffer)
+	case ENCODING_DEFLATE:
+		writer = zlib.NewWriter(&buffer)
+	case ENCODING_BROTLI:
+		writer = brotli.NewWriter(&buffer)
+	case ENCODING_ZSTD:
+		zw, err := zstd.NewWriter(&buffer)
+		if err != nil {
+			return nil, err
+		}
+		writer = zw
+	default:
+		return nil, fmt.Errorf("fiftyrest: unsupported request compression codec %q", codec)
+	}
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+/**
+ * Sends a request body with the given headers
+ */
+type bodySender func(content []byte, headers Headers) (RawResponse, error)
+
+/**
+ * Send a body compressed with the config's codec when compression is on and the body is at least
+ * RequestCompressionThreshold bytes. Bodies which already have a Content-Encoding are sent as they are.
+ * A server answering 415 Unsupported Media Type to a compressed body gets the body again, uncompressed.
+ * @param config the current config
+ * @param enabled if compression is on for the request, usually Config.RequestCompressionOn unless overridden
+ * @param content the body
+ * @param headers the request headers
+ * @param send sends one attempt
+ * @return the response of the last attempt
+ */
+func sendCompressed(config *Config, enabled bool, content []byte, headers Headers, send bodySender) (RawResponse, error) {
+	if !enabled || len(content) < config.RequestCompressionThreshold || headers.ContainsKey(CONTENT_ENCODING) {
+		return send(content, headers)
+	}
+	codec := config.RequestCompressionCodec
+	if codec == "" {
+		codec = ENCODING_GZIP
+	}
+	compressed, err := compressBody(codec, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressedHeaders = *NewHeaders()
+	compressedHeaders.PutAll(headers)
+	compressedHeaders.Replace(CONTENT_ENCODING, codec)
+	if compressedHeaders.ContainsKey(CONTENT_LENGTH) {
+		compressedHeaders.Replace(CONTENT_LENGTH, strconv.Itoa(len(compressed)))
+	}
+	response, err := send(compressed, compressedHeaders)
+	if err != nil || response.GetStatus() != UNSUPPORTED_MEDIA_TYPE {
+		return response, err
+	}
+	response.GetContent().Close()
+	return send(content, headers)
+}
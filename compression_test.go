@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func gzipped(t *testing.T, content []byte) []byte {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	gz.Write(content)
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// closeCounter counts how often the body under the decompressors was closed
+type closeCounter struct {
+	io.Reader
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func decompress(t *testing.T, contentEncoding string, content []byte) string {
+	body := &closeCounter{Reader: bytes.NewReader(content)}
+	reader, err := decompressBody(contentEncoding, body)
+	if err != nil {
+		t.Fatalf("%s: %v", contentEncoding, err)
+	}
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("%s: %v", contentEncoding, err)
+	}
+	reader.Close()
+	if body.closed != 1 {
+		t.Errorf("%s: the body was closed %d times", contentEncoding, body.closed)
+	}
+	return string(decoded)
+}
+
+func TestDecompressBody(t *testing.T) {
+	text := []byte(strings.Repeat("decompress me ", 20))
+	if decoded := decompress(t, "x-gzip", gzipped(t, text)); decoded != string(text) {
+		t.Errorf("x-gzip: got %q", decoded)
+	}
+
+	var raw bytes.Buffer
+	writer, _ := flate.NewWriter(&raw, flate.DefaultCompression)
+	writer.Write(text)
+	writer.Close()
+	if decoded := decompress(t, "deflate", raw.Bytes()); decoded != string(text) {
+		t.Errorf("raw deflate: got %q", decoded)
+	}
+
+	// codings are listed in the order they were applied, identity is skipped
+	twice := gzipped(t, gzipped(t, text))
+	if decoded := decompress(t, "gzip, identity, GZIP", twice); decoded != string(text) {
+		t.Errorf("stacked: got %q", decoded)
+	}
+
+	if _, err := decompressBody("gzip", io.NopCloser(strings.NewReader("not gzip"))); err == nil {
+		t.Error("an invalid gzip body was accepted")
+	}
+	if _, err := decompressBody("compress", io.NopCloser(strings.NewReader(""))); err == nil {
+		t.Error("an unsupported coding was accepted")
+	}
+}
+
+func TestCanDecompress(t *testing.T) {
+	tests := map[string]bool{
+		"":                       false,
+		"identity":               false,
+		"gzip":                   true,
+		"Deflate":                true,
+		"br, zstd":               true,
+		"gzip, compress":         false,
+		" x-gzip , identity ":    true,
+		"gzip, unknown-encoding": false,
+	}
+	for contentEncoding, expected := range tests {
+		if can := canDecompress(contentEncoding); can != expected {
+			t.Errorf("%q: got %v", contentEncoding, can)
+		}
+	}
+}
+
+func TestNegotiateAcceptEncoding(t *testing.T) {
+	headers := NewHeaders()
+	negotiateAcceptEncoding(headers, false)
+	if headers.ContainsKey(ACCEPT_ENCODING) {
+		t.Error("Accept-Encoding sent without decompression")
+	}
+	negotiateAcceptEncoding(headers, true)
+	if value := headers.GetFirst(ACCEPT_ENCODING); value != DEFAULT_ACCEPT_ENCODING {
+		t.Errorf("got %q", value)
+	}
+	headers = NewHeaders()
+	headers.Add(ACCEPT_ENCODING, ENCODING_IDENTITY)
+	negotiateAcceptEncoding(headers, true)
+	if values := headers.Get(ACCEPT_ENCODING); len(values) != 1 || values[0] != ENCODING_IDENTITY {
+		t.Errorf("the caller's Accept-Encoding was changed: %v", values)
+	}
+}
+
+func TestCompressBodyRoundTrip(t *testing.T) {
+	text := []byte(strings.Repeat("compress me ", 50))
+	for _, codec := range []string{ENCODING_GZIP, ENCODING_DEFLATE, ENCODING_BROTLI, "ZSTD"} {
+		compressed, err := compressBody(codec, text)
+		if err != nil {
+			t.Fatalf("%s: %v", codec, err)
+		}
+		if len(compressed) >= len(text) {
+			t.Errorf("%s: %d bytes compressed to %d", codec, len(text), len(compressed))
+		}
+		if decoded := decompress(t, codec, compressed); decoded != string(text) {
+			t.Errorf("%s: got %q", codec, decoded)
+		}
+	}
+	if _, err := compressBody("compress", text); err == nil {
+		t.Error("an unsupported codec was accepted")
+	}
+}
+
+// sentBody is one attempt seen by the bodySender of sendCompressed
+type sentBody struct {
+	content []byte
+	headers Headers
+}
+
+func recordingSender(statuses ...int) (bodySender, *[]sentBody) {
+	sent := make([]sentBody, 0)
+	return func(content []byte, headers Headers) (RawResponse, error) {
+		status := statuses[len(sent)]
+		sent = append(sent, sentBody{content: content, headers: headers})
+		response := &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}
+		return NewHttpRawResponse(response, NewDefaultConfig(), 0), nil
+	}, &sent
+}
+
+func TestSendCompressed(t *testing.T) {
+	config := NewDefaultConfig()
+	config.RequestCompressionThreshold = 10
+	config.RequestCompressionCodec = ""
+	content := []byte(strings.Repeat("a", 100))
+	headers := NewHeaders()
+	headers.Add(CONTENT_LENGTH, "100")
+
+	send, sent := recordingSender(200)
+	if _, err := sendCompressed(config, true, content, *headers, send); err != nil {
+		t.Fatal(err)
+	}
+	attempt := (*sent)[0]
+	if attempt.headers.GetFirst(CONTENT_ENCODING) != ENCODING_GZIP || attempt.headers.GetFirst(CONTENT_LENGTH) != strconv.Itoa(len(attempt.content)) {
+		t.Errorf("sent with %v", attempt.headers.GetFirst(CONTENT_ENCODING))
+	}
+	if decompress(t, ENCODING_GZIP, attempt.content) != string(content) {
+		t.Error("the compressed body does not decode")
+	}
+	if headers.ContainsKey(CONTENT_ENCODING) {
+		t.Error("the headers of the caller were changed")
+	}
+
+	for name, enabled := range map[string]bool{"off": false, "below threshold": true} {
+		body := content
+		if name == "below threshold" {
+			body = content[:9]
+		}
+		send, sent = recordingSender(200)
+		sendCompressed(config, enabled, body, *headers, send)
+		if (*sent)[0].headers.ContainsKey(CONTENT_ENCODING) {
+			t.Errorf("%s: the body was compressed", name)
+		}
+	}
+	encoded := NewHeaders()
+	encoded.Add(CONTENT_ENCODING, ENCODING_BROTLI)
+	send, sent = recordingSender(200)
+	sendCompressed(config, true, content, *encoded, send)
+	if !bytes.Equal((*sent)[0].content, content) {
+		t.Error("a body with a Content-Encoding was compressed again")
+	}
+}
+
+func TestSendCompressedFallsBackOn415(t *testing.T) {
+	config := NewDefaultConfig()
+	config.RequestCompressionThreshold = 1
+	config.RequestCompressionCodec = ENCODING_ZSTD
+	content := []byte("uncompressed body")
+	send, sent := recordingSender(UNSUPPORTED_MEDIA_TYPE, 200)
+	response, err := sendCompressed(config, true, content, *NewHeaders(), send)
+	if err != nil || response.GetStatus() != 200 {
+		t.Fatalf("got %v", err)
+	}
+	if len(*sent) != 2 || (*sent)[0].headers.GetFirst(CONTENT_ENCODING) != ENCODING_ZSTD {
+		t.Fatalf("sent %d attempts", len(*sent))
+	}
+	if retry := (*sent)[1]; retry.headers.ContainsKey(CONTENT_ENCODING) || !bytes.Equal(retry.content, content) {
+		t.Errorf("the retry was sent with %q", retry.headers.GetFirst(CONTENT_ENCODING))
+	}
+
+	config.RequestCompressionCodec = "compress"
+	send, sent = recordingSender(200)
+	if _, err := sendCompressed(config, true, content, *NewHeaders(), send); err == nil || len(*sent) != 0 {
+		t.Error("an unsupported codec was sent")
+	}
+}
+
+func TestDecompressBodyReleasesReadersOnError(t *testing.T) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// large enough that the decoder is still busy with later blocks when gzip gives up on the first bytes
+	content := encoder.EncodeAll(bytes.Repeat([]byte("zstd but not gzip "), 1<<20), nil)
+	encoder.Close()
+
+	// zstd decodes streams on goroutines of its own only with more than one P
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		if _, err := decompressBody("gzip, zstd", io.NopCloser(bytes.NewReader(content))); err == nil {
+			t.Fatal("a zstd body which is not gzip inside was accepted")
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines of the zstd readers are still running", runtime.NumGoroutine()-before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,48 +1,266 @@
 package main
 
-type Config struct {
-	// DEFAULT_CONNECTION_TIMEOUT int //  = 10000;
-	// int DEFAULT_MAX_CONNECTIONS = 200;
-	// int DEFAULT_MAX_PER_ROUTE = 20;
-	// int DEFAULT_CONNECT_TIMEOUT = 10000;
-	// int DEFAULT_SOCKET_TIMEOUT = 60000;
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_CONNECTION_TIMEOUT    = 10000
+	DEFAULT_MAX_CONNECTIONS       = 200
+	DEFAULT_MAX_PER_ROUTE         = 20
+	DEFAULT_CONNECT_TIMEOUT       = 10000
+	DEFAULT_SOCKET_TIMEOUT        = 60000
+	DEFAULT_ASYNC_WORKERS         = 20
+	DEFAULT_ASYNC_QUEUE_SIZE      = 1000
+	DEFAULT_COMPRESSION_THRESHOLD = 1024
+)
 
+type Config struct {
 	// Client client;
-	// private Optional<AsyncClient> asyncClient = Optional.empty();
-	// private Optional<ObjectMapper> objectMapper = Optional.of(new JsonObjectMapper());
+	ObjectMapper ObjectMapper // default = JsonObjectMapper, used when no mapper of Mappers matches the Content-Type
+	// the ObjectMappers per content type, also used for the Accept header, default = NewDefaultMapperRegistry()
+	Mappers *MapperRegistry
 
 	// private List<HttpRequestInterceptor> apacheinterceptors = new ArrayList<>();
-	// private Headers headers;
+	// sent with every request unless the request sets the same header, default = User-Agent: DEFAULT_USER_AGENT
+	DefaultHeaders Headers
 	// private Proxy proxy;
-	ConnectionTimeout       int
-	SocketTimeout           int
+	ConnectionTimeout       int // millis, the Dial timeout unless Timeouts.Dial is set
+	SocketTimeout           int // millis, the IdleRead timeout unless Timeouts.IdleRead is set
+	Timeouts                Timeouts
 	MaxTotal                int
 	MaxPerRoute             int
 	FollowRedirects         bool
 	CookieManagement        bool
 	UseSystemProperties     bool   // default value is true
 	defaultResponseEncoding string // = StandardCharsets.UTF_8.name();
-	// private Function<Config, AsyncClient> asyncBuilder;
 	// private Function<Config, Client> clientBuilder;
-	RequestCompressionOn bool // default = true;
-	AutomaticRetries     bool
-	VerifySsl            bool // default = true;
-	// private boolean addShutdownHook = false;
-	// private KeyStore keystore;
-	// private Supplier<String> keystorePassword = () -> null;
+	RequestCompressionOn    bool // default = true;
+	ResponseDecompressionOn bool // decode gzip, deflate, br and zstd response bodies, default = true
+	ErrorOnFailureStatus    bool // turn responses outside 2xx into a StatusError, default = false
+	// request bodies smaller than this many bytes are sent uncompressed, default = 1024
+	RequestCompressionThreshold int
+	// the codec request bodies are compressed with: gzip, deflate, br or zstd, default = gzip
+	RequestCompressionCodec string
+	AutomaticRetries        bool
+	// how often a failed request is sent again when AutomaticRetries is on, default = 3
+	MaxRetries int
+	VerifySsl  bool // default = true;
+	// send header names in the usual casing like Content-Type instead of as they were added, default = false
+	CanonicalizeHeaderNames bool
+	// allow requests to set Connection, TE, Trailer, Transfer-Encoding and Upgrade, default = false
+	AllowHopByHopHeaders bool
+	// root CAs, client certificates, TLS versions, cipher suites, public key pinning and hostname verification
+	Tls             TlsConfig
+	AddShutdownHook bool // close clients on SIGINT and SIGTERM, default = false
+	// how long Close waits for running requests, default = 30s
+	ShutdownTimeout time.Duration
+	shutdownHooks   []func(ctx context.Context) error
 	// private String cookieSpec;
 	// private UniMetric metrics = new NoopMetric();
 	ttl int64 //default value = -1;
-	// private SSLContext sslContext;
-	// private String[] ciphers;
-	// private String[] protocols;
-	// private CompoundInterceptor interceptor = new CompoundInterceptor();
-	// private HostnameVerifier hostnameVerifier;
-	DefaultBaseUrl string
+	// how long a request waits for a free connection when MaxTotal or MaxPerRoute is reached, 0 fails fast, default = 30s
+	PoolWaitTimeout time.Duration
+	// idle connections are closed after this long, default = 90s
+	IdleConnectionTimeout time.Duration
+	connectionPool        *ConnectionPool
+	interceptors          []Interceptor
+	DefaultBaseUrl        string
 	// private CacheManager cache;
 
+	AsyncWorkers   int // number of goroutines executing async requests, default = 20
+	AsyncQueueSize int // async requests waiting for a worker before submitting blocks, default = 1000
+	asyncExecutor  *AsyncExecutor
+	// the clients using this config which were not closed yet
+	clients int
 }
 
-func NewDefaultConfig() {
+var (
+	asyncExecutorLock  sync.Mutex
+	connectionPoolLock sync.Mutex
+	clientsLock        sync.Mutex
+)
+
+func NewDefaultConfig() *Config {
+	var config = new(Config)
+	config.ConnectionTimeout = DEFAULT_CONNECTION_TIMEOUT
+	config.SocketTimeout = DEFAULT_SOCKET_TIMEOUT
+	config.MaxTotal = DEFAULT_MAX_CONNECTIONS
+	config.MaxPerRoute = DEFAULT_MAX_PER_ROUTE
+	config.FollowRedirects = true
+	config.CookieManagement = true
+	config.UseSystemProperties = true
+	config.defaultResponseEncoding = "UTF-8"
+	config.DefaultHeaders = *NewHeaders()
+	config.DefaultHeaders.Add(USER_AGENT, DEFAULT_USER_AGENT)
+	config.RequestCompressionOn = true
+	config.ResponseDecompressionOn = true
+	config.RequestCompressionThreshold = DEFAULT_COMPRESSION_THRESHOLD
+	config.RequestCompressionCodec = ENCODING_GZIP
+	config.ObjectMapper = NewJsonObjectMapper()
+	config.Mappers = NewDefaultMapperRegistry()
+	config.AutomaticRetries = true
+	config.MaxRetries = DEFAULT_MAX_RETRIES
+	config.VerifySsl = true
+	config.ttl = -1
+	config.PoolWaitTimeout = DEFAULT_POOL_WAIT_TIMEOUT
+	config.IdleConnectionTimeout = DEFAULT_IDLE_TIMEOUT
+	config.ShutdownTimeout = DEFAULT_SHUTDOWN_TIMEOUT
+	config.AsyncWorkers = DEFAULT_ASYNC_WORKERS
+	config.AsyncQueueSize = DEFAULT_ASYNC_QUEUE_SIZE
+	return config
+}
+
+/**
+ * Set the charset to decode response bodies with when neither the server nor the request names one
+ * @param encoding a charset name such as UTF-8 or windows-1251
+ */
+func (c *Config) SetDefaultResponseEncoding(encoding string) {
+	c.defaultResponseEncoding = encoding
+}
+
+/**
+ * @return the charset to decode response bodies with when neither the server nor the request names one
+ */
+func (c *Config) GetDefaultResponseEncoding() string {
+	return c.defaultResponseEncoding
+}
+
+/**
+ * Set a header for every request, replacing the default with the same name
+ * @param name the name of the header
+ * @param value the value, "" removes the default
+ */
+func (c *Config) SetDefaultHeader(name string, value string) {
+	if value == "" {
+		c.DefaultHeaders.remove(name)
+		return
+	}
+	c.DefaultHeaders.Replace(name, value)
+}
+
+/**
+ * Add a value to a header sent with every request
+ * @param name the name of the header
+ * @param value the value
+ */
+func (c *Config) AddDefaultHeader(name string, value string) {
+	c.DefaultHeaders.Add(name, value)
+}
+
+/**
+ * Set a header for every request whose value is supplied each time a request is sent, e.g. an auth token
+ * @param name the name of the header
+ * @param supplier supplies the value, an error fails the request
+ */
+func (c *Config) SetDefaultHeaderSupplier(name string, supplier HeaderSupplier) {
+	c.DefaultHeaders.ReplaceSupplier(name, supplier)
+}
+
+/**
+ * @return the ObjectMapper, a JsonObjectMapper when none was set
+ */
+func (c *Config) GetObjectMapper() ObjectMapper {
+	if c.ObjectMapper == nil {
+		return NewJsonObjectMapper()
+	}
+	return c.ObjectMapper
+}
+
+/**
+ * @return Timeouts with Dial and IdleRead taken from ConnectionTimeout and SocketTimeout where they are not set
+ */
+func (c *Config) GetTimeouts() Timeouts {
+	var fallback Timeouts
+	fallback.Dial = time.Duration(c.ConnectionTimeout) * time.Millisecond
+	fallback.IdleRead = time.Duration(c.SocketTimeout) * time.Millisecond
+	return fallback.Merge(c.Timeouts)
+}
+
+/**
+ * Add an interceptor which is called for every request of the clients using this config.
+ * Interceptors are called in the order they were added.
+ * @param interceptor the interceptor
+ */
+func (c *Config) AddInterceptor(interceptor Interceptor) {
+	c.interceptors = append(c.interceptors, interceptor)
+}
+
+/**
+ * @return the interceptors in the order they were added
+ */
+func (c *Config) GetInterceptors() []Interceptor {
+	return c.interceptors
+}
+
+/**
+ * Add a function which runs when the last open client using this config is closed, after the running requests
+ * finished, for example to flush a cache or metrics
+ * @param hook the function, ctx carries the remaining shutdown deadline
+ */
+func (c *Config) OnShutdown(hook func(ctx context.Context) error) {
+	c.shutdownHooks = append(c.shutdownHooks, hook)
+}
+
+/**
+ * Cap the lifetime of pooled connections, after which they are closed once idle
+ * @param ttl the lifetime, <= 0 for no limit
+ */
+func (c *Config) SetConnectionTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		c.ttl = -1
+		return
+	}
+	c.ttl = ttl.Milliseconds()
+}
+
+/**
+ * @return the lifetime of pooled connections, or -1 when there is no limit
+ */
+func (c *Config) GetConnectionTTL() time.Duration {
+	if c.ttl <= 0 {
+		return -1
+	}
+	return time.Duration(c.ttl) * time.Millisecond
+}
+
+/**
+ * Get the pool enforcing MaxTotal, MaxPerRoute, the connection ttl and IdleConnectionTimeout.
+ * It is created on first use, changing those settings afterwards has no effect.
+ * Its dialer has no timeout of its own, the Dial timeout of each request bounds the dial.
+ * @return the pool
+ */
+func (c *Config) GetConnectionPool() *ConnectionPool {
+	connectionPoolLock.Lock()
+	defer connectionPoolLock.Unlock()
+	if c.connectionPool == nil {
+		c.connectionPool = NewConnectionPool(c.MaxTotal, c.MaxPerRoute, c.GetConnectionTTL(), c.IdleConnectionTimeout, c.PoolWaitTimeout, new(net.Dialer))
+	}
+	return c.connectionPool
+}
+
+/**
+ * Build the crypto/tls configuration from Tls and VerifySsl
+ * @return the tls config
+ */
+func (c *Config) BuildTlsConfig() (*tls.Config, error) {
+	return c.Tls.Build(c.VerifySsl)
+}
 
+/**
+ * Get the worker pool used by the As*Async methods. It is started on first use
+ * with AsyncWorkers and AsyncQueueSize, changing those afterwards has no effect.
+ * @return the executor
+ */
+func (c *Config) GetAsyncExecutor() *AsyncExecutor {
+	asyncExecutorLock.Lock()
+	defer asyncExecutorLock.Unlock()
+	if c.asyncExecutor == nil {
+		c.asyncExecutor = NewAsyncExecutor(c.AsyncWorkers, c.AsyncQueueSize)
+	}
+	return c.asyncExecutor
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigTimeoutsFallBackToMillis(t *testing.T) {
+	var config = NewDefaultConfig()
+	config.ConnectionTimeout = 250
+	config.Timeouts.IdleRead = time.Second
+
+	timeouts := config.GetTimeouts()
+	if timeouts.Dial != 250*time.Millisecond {
+		t.Errorf("Dial = %v", timeouts.Dial)
+	}
+	if timeouts.IdleRead != time.Second {
+		t.Errorf("IdleRead = %v", timeouts.IdleRead)
+	}
+}
+
+func TestConfigConnectionPoolLeavesDialTimeoutToRequests(t *testing.T) {
+	var config = NewDefaultConfig()
+	config.ConnectionTimeout = 10
+	config.Timeouts.Dial = time.Minute
+
+	pool := config.GetConnectionPool()
+	defer pool.Close()
+	if pool.dialer.Timeout != 0 {
+		t.Errorf("the dialer caps the Dial timeout of %v at %v", config.GetTimeouts().Dial, pool.dialer.Timeout)
+	}
+}
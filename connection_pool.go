@@ -0,0 +1,479 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrPoolExhausted = errors.New("fiftyrest: connection pool exhausted")
+
+const (
+	DEFAULT_POOL_WAIT_TIMEOUT = 30 * time.Second
+	DEFAULT_IDLE_TIMEOUT      = 90 * time.Second
+)
+
+/**
+ * Connection counts of a route (host:port) or of the whole pool
+ */
+type PoolStats struct {
+	// connections in use by a request
+	Leased int
+	// open connections waiting to be reused
+	Idle int
+	// dials waiting for a free slot
+	Pending int
+	// the limit of open connections
+	Max int
+}
+
+type routeState struct {
+	open    int
+	dialing int
+	leased  int
+	pending int
+}
+
+type pooledConn struct {
+	net.Conn
+	pool      *ConnectionPool
+	route     string
+	created   time.Time
+	idleSince time.Time
+	leased    bool
+	closeOnce sync.Once
+	// the transport holding the connection while it is idle, nil when it was dialed with DialContext
+	transport *http.Transport
+}
+
+func (c *pooledConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		c.pool.release(c)
+	})
+	return err
+}
+
+/**
+ * Limits and tracks the connections of a net/http Transport like the Apache HttpClient pool does:
+ * at most MaxTotal open connections, at most MaxPerRoute per host:port, idle connections closed after
+ * the idle timeout and every connection closed once it is older than the ttl.
+ * When the pool is full a dial first closes the longest idle connection of any route, then waits for a free slot.
+ * Leased and idle counts rely on the trace added by WithTrace; without it, and for HTTP/2 connections,
+ * a connection counts as leased while it is open and only the transport's idle timeout closes it.
+ * Idle connections of a transport configured with ConfigureTransport are closed through its CloseIdleConnections,
+ * so the transport never hands out a connection the pool is closing. That closes its other idle connections too.
+ */
+type ConnectionPool struct {
+	maxTotal    int
+	maxPerRoute int
+	ttl         time.Duration
+	idleTimeout time.Duration
+	waitTimeout time.Duration
+	dialer      *net.Dialer
+
+	lock    sync.Mutex
+	changed chan struct{}
+	total   int
+	routes  map[string]*routeState
+	conns   map[*pooledConn]bool
+	stop    chan struct{}
+	closed  bool
+}
+
+/**
+ * Create a pool and start evicting idle and expired connections.
+ * @param maxTotal the limit of open connections, <= 0 for no limit
+ * @param maxPerRoute the limit of open connections per host:port, <= 0 for no limit
+ * @param ttl the maximum lifetime of a connection, <= 0 for no limit
+ * @param idleTimeout how long a connection may stay idle, <= 0 to keep idle connections
+ * @param waitTimeout how long a dial waits for a free slot. 0 fails fast with ErrPoolExhausted, < 0 waits until the request's context ends
+ * @param dialer dials new connections, a default net.Dialer when nil
+ * @return the pool
+ */
+func NewConnectionPool(maxTotal int, maxPerRoute int, ttl time.Duration, idleTimeout time.Duration, waitTimeout time.Duration, dialer *net.Dialer) *ConnectionPool {
+	if dialer == nil {
+		dialer = new(net.Dialer)
+	}
+	var pool = new(ConnectionPool)
+	pool.maxTotal = maxTotal
+	pool.maxPerRoute = maxPerRoute
+	pool.ttl = ttl
+	pool.idleTimeout = idleTimeout
+	pool.waitTimeout = waitTimeout
+	pool.dialer = dialer
+	pool.changed = make(chan struct{})
+	pool.routes = make(map[string]*routeState)
+	pool.conns = make(map[*pooledConn]bool)
+	pool.stop = make(chan struct{})
+	if interval := pool.evictInterval(); interval > 0 {
+		go pool.evict(interval)
+	}
+	return pool
+}
+
+/**
+ * Make the transport dial through this pool
+ * @param transport the transport
+ */
+func (p *ConnectionPool) ConfigureTransport(transport *http.Transport) {
+	transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+		return p.dial(ctx, transport, network, address)
+	}
+	transport.MaxConnsPerHost = 0
+	transport.MaxIdleConns = p.maxTotal
+	transport.IdleConnTimeout = p.idleTimeout
+	// net/http keeps only 2 idle connections per host unless told otherwise
+	switch {
+	case p.maxPerRoute > 0:
+		transport.MaxIdleConnsPerHost = p.maxPerRoute
+	case p.maxTotal > 0:
+		transport.MaxIdleConnsPerHost = p.maxTotal
+	default:
+		transport.MaxIdleConnsPerHost = math.MaxInt32
+	}
+}
+
+/**
+ * Attach the trace which tells the pool when a connection is leased and returned. Call it on
+ * the context of every request sent through a transport using this pool.
+ * @param ctx the context of the request
+ * @return the context with the trace
+ */
+func (p *ConnectionPool) WithTrace(ctx context.Context) context.Context {
+	var current atomic.Pointer[pooledConn]
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if conn := p.lookup(info.Conn); conn != nil {
+				current.Store(conn)
+				p.setLeased(conn, true)
+			}
+		},
+		PutIdleConn: func(err error) {
+			if conn := current.Load(); conn != nil && err == nil {
+				p.setLeased(conn, false)
+			}
+		},
+	}
+	return httptrace.WithClientTrace(ctx, trace)
+}
+
+/**
+ * Dial a connection, waiting for a free slot when the pool is full. Meant for http.Transport.DialContext
+ */
+func (p *ConnectionPool) DialContext(ctx context.Context, network string, address string) (net.Conn, error) {
+	return p.dial(ctx, nil, network, address)
+}
+
+func (p *ConnectionPool) dial(ctx context.Context, transport *http.Transport, network string, address string) (net.Conn, error) {
+	if err := p.reserve(ctx, address); err != nil {
+		return nil, err
+	}
+	conn, err := p.dialer.DialContext(ctx, network, address)
+	if err != nil {
+		p.unreserve(address)
+		return nil, err
+	}
+	now := time.Now()
+	// a new connection counts as leased until the trace reports it returned, so it is never taken for idle before its first request
+	pooled := &pooledConn{Conn: conn, pool: p, route: address, created: now, idleSince: now, leased: true, transport: transport}
+	p.lock.Lock()
+	p.conns[pooled] = true
+	state := p.route(address)
+	state.dialing--
+	state.leased++
+	p.lock.Unlock()
+	return pooled, nil
+}
+
+func (p *ConnectionPool) reserve(ctx context.Context, route string) error {
+	parent := ctx
+	if p.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.waitTimeout)
+		defer cancel()
+	}
+	evicted := make(map[*pooledConn]bool)
+	for {
+		p.lock.Lock()
+		if p.closed {
+			p.lock.Unlock()
+			return ErrPoolExhausted
+		}
+		state := p.route(route)
+		routeFull := p.maxPerRoute > 0 && state.open >= p.maxPerRoute
+		totalFull := p.maxTotal > 0 && p.total >= p.maxTotal
+		if !routeFull && !totalFull {
+			state.open++
+			state.dialing++
+			p.total++
+			p.lock.Unlock()
+			return nil
+		}
+		// a victim which survived closeConns was taken by its transport in the meantime, wait for it instead
+		victim := p.oldestIdle(route, routeFull)
+		if victim != nil && !evicted[victim] {
+			evicted[victim] = true
+			p.lock.Unlock()
+			p.closeConns([]*pooledConn{victim})
+			continue
+		}
+		if p.waitTimeout == 0 {
+			p.lock.Unlock()
+			return ErrPoolExhausted
+		}
+		state.pending++
+		changed := p.changed
+		p.lock.Unlock()
+
+		var err error
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		p.lock.Lock()
+		state.pending--
+		p.lock.Unlock()
+		if err != nil {
+			if parent.Err() != nil {
+				return parent.Err()
+			}
+			return ErrPoolExhausted
+		}
+	}
+}
+
+// the longest idle connection which frees a slot, of the same route when the route is full. Called with the lock held
+func (p *ConnectionPool) oldestIdle(route string, sameRoute bool) *pooledConn {
+	var oldest *pooledConn
+	for conn := range p.conns {
+		if conn.leased || (sameRoute && conn.route != route) {
+			continue
+		}
+		if oldest == nil || conn.idleSince.Before(oldest.idleSince) {
+			oldest = conn
+		}
+	}
+	return oldest
+}
+
+func (p *ConnectionPool) unreserve(route string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	state := p.route(route)
+	state.open--
+	state.dialing--
+	p.total--
+	if state.open == 0 && state.pending == 0 {
+		delete(p.routes, route)
+	}
+	p.signal()
+}
+
+func (p *ConnectionPool) release(conn *pooledConn) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if !p.conns[conn] {
+		return
+	}
+	delete(p.conns, conn)
+	state := p.route(conn.route)
+	state.open--
+	if conn.leased {
+		state.leased--
+	}
+	p.total--
+	if state.open == 0 && state.pending == 0 {
+		delete(p.routes, conn.route)
+	}
+	p.signal()
+}
+
+// wake every waiting dial. Called with the lock held
+func (p *ConnectionPool) signal() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// called with the lock held
+func (p *ConnectionPool) route(route string) *routeState {
+	state, ok := p.routes[route]
+	if !ok {
+		state = new(routeState)
+		p.routes[route] = state
+	}
+	return state
+}
+
+func (p *ConnectionPool) lookup(conn net.Conn) *pooledConn {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	pooled, ok := conn.(*pooledConn)
+	if !ok || pooled.pool != p {
+		return nil
+	}
+	return pooled
+}
+
+func (p *ConnectionPool) setLeased(conn *pooledConn, leased bool) {
+	p.lock.Lock()
+	if !p.conns[conn] || conn.leased == leased {
+		p.lock.Unlock()
+		return
+	}
+	conn.leased = leased
+	state := p.route(conn.route)
+	if leased {
+		state.leased++
+	} else {
+		state.leased--
+		conn.idleSince = time.Now()
+	}
+	expired := !leased && p.expired(conn, conn.idleSince)
+	p.signal()
+	p.lock.Unlock()
+	if expired {
+		p.closeConns([]*pooledConn{conn})
+	}
+}
+
+// called with the lock held
+func (p *ConnectionPool) expired(conn *pooledConn, now time.Time) bool {
+	if p.ttl > 0 && now.Sub(conn.created) >= p.ttl {
+		return true
+	}
+	return p.idleTimeout > 0 && !conn.leased && now.Sub(conn.idleSince) >= p.idleTimeout
+}
+
+func (p *ConnectionPool) evictInterval() time.Duration {
+	interval := p.idleTimeout
+	if p.ttl > 0 && (interval <= 0 || p.ttl < interval) {
+		interval = p.ttl
+	}
+	if interval <= 0 {
+		return 0
+	}
+	if interval /= 2; interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+func (p *ConnectionPool) evict(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case now := <-ticker.C:
+			p.closeIdle(func(conn *pooledConn) bool {
+				return p.expired(conn, now)
+			})
+		}
+	}
+}
+
+func (p *ConnectionPool) closeIdle(match func(conn *pooledConn) bool) {
+	victims := make([]*pooledConn, 0)
+	p.lock.Lock()
+	for conn := range p.conns {
+		if !conn.leased && match(conn) {
+			victims = append(victims, conn)
+		}
+	}
+	p.lock.Unlock()
+	p.closeConns(victims)
+}
+
+/**
+ * Close idle connections. Those of a transport are closed by its CloseIdleConnections, which takes
+ * them out of its idle list first, closing them underneath it could race with their reuse
+ * @param conns the connections
+ */
+func (p *ConnectionPool) closeConns(conns []*pooledConn) {
+	transports := make(map[*http.Transport]bool)
+	for _, conn := range conns {
+		if conn.transport != nil {
+			transports[conn.transport] = true
+		} else {
+			conn.Close()
+		}
+	}
+	for transport := range transports {
+		transport.CloseIdleConnections()
+	}
+}
+
+/**
+ * Close every idle connection now
+ */
+func (p *ConnectionPool) CloseIdleConnections() {
+	p.closeIdle(func(conn *pooledConn) bool {
+		return true
+	})
+}
+
+/**
+ * Stop the evictor, fail further dials with ErrPoolExhausted and close the idle connections.
+ * Leased connections are closed by their requests.
+ */
+func (p *ConnectionPool) Close() {
+	p.lock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.stop)
+		p.signal()
+	}
+	p.lock.Unlock()
+	p.CloseIdleConnections()
+}
+
+/**
+ * @return the counts of every route with open connections or pending dials, keyed by host:port
+ */
+func (p *ConnectionPool) GetStats() map[string]PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats := make(map[string]PoolStats, len(p.routes))
+	for route, state := range p.routes {
+		stats[route] = PoolStats{Leased: state.leased, Idle: state.open - state.dialing - state.leased, Pending: state.pending, Max: p.maxPerRoute}
+	}
+	return stats
+}
+
+/**
+ * @return the counts of the whole pool
+ */
+func (p *ConnectionPool) GetTotalStats() PoolStats {
+	total := PoolStats{Max: p.maxTotal}
+	for _, stats := range p.GetStats() {
+		total.Leased += stats.Leased
+		total.Idle += stats.Idle
+		total.Pending += stats.Pending
+	}
+	return total
+}
+
+/**
+ * @return the routes with open connections or pending dials, sorted
+ */
+func (p *ConnectionPool) GetRoutes() []string {
+	stats := p.GetStats()
+	routes := make([]string, 0, len(stats))
+	for route := range stats {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	return routes
+}
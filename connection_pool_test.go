@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listen accepts connections on a local port and holds them until the test ends
+func listen(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func newTestPool(t *testing.T, maxTotal int, maxPerRoute int, waitTimeout time.Duration) *ConnectionPool {
+	pool := NewConnectionPool(maxTotal, maxPerRoute, 0, 0, waitTimeout, nil)
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func dial(t *testing.T, pool *ConnectionPool, address string) net.Conn {
+	conn, err := pool.DialContext(context.Background(), "tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestConnectionPoolFailsFastWhenFull(t *testing.T) {
+	first, second := listen(t), listen(t)
+	pool := newTestPool(t, 2, 1, 0)
+	dial(t, pool, first)
+	if _, err := pool.DialContext(context.Background(), "tcp", first); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("MaxPerRoute: got %v", err)
+	}
+	dial(t, pool, second)
+	if _, err := pool.DialContext(context.Background(), "tcp", listen(t)); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("MaxTotal: got %v", err)
+	}
+	if stats := pool.GetStats()[first]; stats != (PoolStats{Leased: 1, Max: 1}) {
+		t.Errorf("route stats %+v", stats)
+	}
+	if stats := pool.GetTotalStats(); stats != (PoolStats{Leased: 2, Max: 2}) {
+		t.Errorf("total stats %+v", stats)
+	}
+}
+
+func TestConnectionPoolWaitsForAFreeSlot(t *testing.T) {
+	address := listen(t)
+	pool := newTestPool(t, 0, 1, -1)
+	conn := dial(t, pool, address)
+
+	dialed := make(chan error, 1)
+	go func() {
+		_, err := pool.DialContext(context.Background(), "tcp", address)
+		dialed <- err
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.GetStats()[address].Pending != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the second dial did not wait")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	conn.Close()
+	if err := <-dialed; err != nil {
+		t.Fatal(err)
+	}
+	if stats := pool.GetStats()[address]; stats != (PoolStats{Leased: 1, Max: 1}) {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestConnectionPoolWaitEnds(t *testing.T) {
+	address := listen(t)
+	pool := newTestPool(t, 0, 1, 20*time.Millisecond)
+	dial(t, pool, address)
+	if _, err := pool.DialContext(context.Background(), "tcp", address); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("wait timeout: got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := newTestPool(t, 0, 1, -1).DialContext(ctx, "tcp", address); err == nil {
+		t.Error("dialed with a cancelled context")
+	}
+	waiting := newTestPool(t, 0, 1, -1)
+	dial(t, waiting, address)
+	if _, err := waiting.DialContext(ctx, "tcp", address); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled wait: got %v", err)
+	}
+	if stats := waiting.GetStats()[address]; stats.Pending != 0 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestConnectionPoolClosesIdleConnectionsForNewOnes(t *testing.T) {
+	first, second := listen(t), listen(t)
+	pool := newTestPool(t, 1, 0, 0)
+	conn := dial(t, pool, first)
+	pool.setLeased(pool.lookup(conn), false)
+	if stats := pool.GetStats()[first]; stats != (PoolStats{Idle: 1}) {
+		t.Errorf("stats %+v", stats)
+	}
+	dial(t, pool, second)
+	if routes := pool.GetRoutes(); len(routes) != 1 || routes[0] != second {
+		t.Errorf("routes %v", routes)
+	}
+	if _, err := conn.Write([]byte("x")); err == nil {
+		t.Error("the idle connection was not closed")
+	}
+}
+
+func TestConnectionPoolTracksTransportConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+	pool := newTestPool(t, 10, 2, 0)
+	transport := new(http.Transport)
+	pool.ConfigureTransport(transport)
+	defer transport.CloseIdleConnections()
+
+	for i := 0; i < 3; i++ {
+		request, _ := http.NewRequestWithContext(pool.WithTrace(context.Background()), http.MethodGet, server.URL, nil)
+		response, err := transport.RoundTrip(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats := pool.GetStats()[address]; stats.Leased != 1 {
+			t.Errorf("while reading: %+v", stats)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+	if stats := pool.GetStats()[address]; stats != (PoolStats{Idle: 1, Max: 2}) {
+		t.Errorf("after the requests: %+v", stats)
+	}
+
+	pool.Close()
+	if routes := pool.GetRoutes(); len(routes) != 0 {
+		t.Errorf("Close left %v", routes)
+	}
+	if _, err := pool.DialContext(context.Background(), "tcp", address); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("dial after Close: got %v", err)
+	}
+}
+
+func TestConnectionPoolForgetsRoutesOfFailedDials(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	pool := newTestPool(t, 0, 1, 0)
+	if _, err := pool.DialContext(context.Background(), "tcp", address); err == nil {
+		t.Fatal("dialed a closed port")
+	}
+	if routes := pool.GetRoutes(); len(routes) != 0 {
+		t.Errorf("a failed dial left %v", routes)
+	}
+}
+
+func TestConnectionPoolEvictsThroughTheTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	address := strings.TrimPrefix(server.URL, "http://")
+	pool := NewConnectionPool(0, 0, 20*time.Millisecond, 0, 0, nil)
+	defer pool.Close()
+	transport := new(http.Transport)
+	pool.ConfigureTransport(transport)
+	defer transport.CloseIdleConnections()
+
+	get := func() {
+		request, _ := http.NewRequestWithContext(pool.WithTrace(context.Background()), http.MethodGet, server.URL, nil)
+		response, err := transport.RoundTrip(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+	get()
+	time.Sleep(30 * time.Millisecond)
+	pool.closeIdle(func(conn *pooledConn) bool {
+		return pool.expired(conn, time.Now())
+	})
+	if routes := pool.GetRoutes(); len(routes) != 0 {
+		t.Errorf("the expired connection is still open: %v", pool.GetStats())
+	}
+	// the transport dropped the connection from its idle list, so the next request dials a new one
+	get()
+	if stats := pool.GetStats()[address]; stats.Idle != 1 {
+		t.Errorf("after a new request: %+v", stats)
+	}
+}
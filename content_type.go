@@ -1,13 +1,23 @@
 package main
 
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
 type ContentType string
 
 const (
 	APPLICATION_ATOM_XML        ContentType = "application/atom+xml"
+	APPLICATION_CBOR            ContentType = "application/cbor"
 	APPLICATION_FORM_URLENCODED ContentType = "application/x-www-form-urlencoded"
 	APPLICATION_JSON            ContentType = "application/json"
 	APPLICATION_JSON_PATCH      ContentType = "application/json-patch+json"
+	APPLICATION_NDJSON          ContentType = "application/x-ndjson"
 	APPLICATION_OCTET_STREAM    ContentType = "application/octet-stream"
+	APPLICATION_PROBLEM_JSON    ContentType = "application/problem+json"
+	APPLICATION_PROBLEM_XML     ContentType = "application/problem+xml"
 	APPLICATION_SVG_XML         ContentType = "application/svg+xml"
 	APPLICATION_XHTML_XML       ContentType = "application/xhtml+xml"
 	APPLICATION_XML             ContentType = "application/xml"
@@ -24,3 +34,167 @@ const (
 	TEXT_XML                    ContentType = "text/xml"
 	WILDCARD                    ContentType = "*/*"
 )
+
+/**
+ * A parsed Content-Type or Accept entry like <pre>application/vnd.api+json; charset=utf-8</pre>
+ */
+type MediaType struct {
+	// the lower case top level type, e.g. application
+	Type string
+	// the lower case subtype including the suffix, e.g. vnd.api+json
+	Subtype string
+	// the structured syntax suffix without the plus, e.g. json, empty when there is none
+	Suffix string
+	// the parameters with lower case names, e.g. charset and boundary
+	Params map[string]string
+}
+
+/**
+ * Parse a Content-Type header value
+ * @param value the header value
+ * @return the media type, or an error when value is not a type/subtype pair
+ */
+func ParseContentType(value string) (MediaType, error) {
+	var media MediaType
+	essence, params, err := mime.ParseMediaType(value)
+	if err != nil && err != mime.ErrInvalidMediaParameter {
+		return media, fmt.Errorf("fiftyrest: invalid content type %q: %w", value, err)
+	}
+	slash := strings.IndexByte(essence, '/')
+	if slash <= 0 || slash == len(essence)-1 {
+		return media, fmt.Errorf("fiftyrest: invalid content type %q: missing subtype", value)
+	}
+	media.Type = essence[:slash]
+	media.Subtype = essence[slash+1:]
+	if plus := strings.LastIndexByte(media.Subtype, '+'); plus >= 0 {
+		media.Suffix = media.Subtype[plus+1:]
+	}
+	media.Params = params
+	return media, nil
+}
+
+/**
+ * @return the type and subtype without parameters, e.g. application/json
+ */
+func (m MediaType) Essence() string {
+	return m.Type + "/" + m.Subtype
+}
+
+/**
+ * @return the charset parameter, "" when there is none
+ */
+func (m MediaType) GetCharset() string {
+	return m.Params["charset"]
+}
+
+/**
+ * @return the boundary parameter of multipart types, "" when there is none
+ */
+func (m MediaType) GetBoundary() string {
+	return m.Params["boundary"]
+}
+
+/**
+ * @param charset the charset
+ * @return a copy of this media type with the charset parameter replaced
+ */
+func (m MediaType) WithCharset(charset string) MediaType {
+	params := make(map[string]string, len(m.Params)+1)
+	for name, value := range m.Params {
+		params[name] = value
+	}
+	params["charset"] = charset
+	m.Params = params
+	return m
+}
+
+/**
+ * Check if this media type is covered by a pattern. The pattern may use a wildcard for both the type
+ * and the subtype as WILDCARD does, a wildcard subtype like application/* or a wildcard with a suffix like application/*+json.
+ * Parameters of the pattern must be present with the same value, compared case insensitively.
+ * @param pattern the pattern
+ * @return true if this media type matches
+ */
+func (m MediaType) Matches(pattern MediaType) bool {
+	if pattern.Type != "*" && pattern.Type != m.Type {
+		return false
+	}
+	switch {
+	case pattern.Subtype == "*":
+	case strings.HasPrefix(pattern.Subtype, "*+"):
+		if m.Suffix != pattern.Suffix {
+			return false
+		}
+	case pattern.Subtype != m.Subtype:
+		return false
+	}
+	for name, value := range pattern.Params {
+		if !strings.EqualFold(m.Params[name], value) {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * @return true for application/json and any +json type
+ */
+func (m MediaType) IsJson() bool {
+	return (m.Type == "application" && m.Subtype == "json") || m.Suffix == "json"
+}
+
+/**
+ * @return true for application/xml, text/xml and any +xml type
+ */
+func (m MediaType) IsXml() bool {
+	return ((m.Type == "application" || m.Type == "text") && m.Subtype == "xml") || m.Suffix == "xml"
+}
+
+/**
+ * @return true for text/* types which are not xml
+ */
+func (m MediaType) IsText() bool {
+	return m.Type == "text" && !m.IsXml()
+}
+
+/**
+ * @return the media type formatted for a header, parameters in alphabetical order
+ */
+func (m MediaType) String() string {
+	if m.Type == "" {
+		return ""
+	}
+	return mime.FormatMediaType(m.Essence(), m.Params)
+}
+
+/**
+ * @return the parsed content type, see ParseContentType
+ */
+func (c ContentType) Parse() (MediaType, error) {
+	return ParseContentType(string(c))
+}
+
+/**
+ * @param value a Content-Type header value
+ * @return true if value parses and matches this content type, which may use wildcards
+ */
+func (c ContentType) Matches(value string) bool {
+	pattern, err := c.Parse()
+	if err != nil {
+		return false
+	}
+	media, err := ParseContentType(value)
+	return err == nil && media.Matches(pattern)
+}
+
+/**
+ * @param charset the charset
+ * @return this content type with the charset parameter, e.g. application/json; charset=utf-8
+ */
+func (c ContentType) WithCharset(charset string) ContentType {
+	media, err := c.Parse()
+	if err != nil {
+		return c
+	}
+	return ContentType(media.WithCharset(charset).String())
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseContentType(t *testing.T) {
+	media, err := ParseContentType(`Application/Vnd.API+JSON; Charset="UTF-8"; boundary=x`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.Type != "application" || media.Subtype != "vnd.api+json" || media.Suffix != "json" {
+		t.Errorf("got %+v", media)
+	}
+	if media.Essence() != "application/vnd.api+json" || media.GetCharset() != "UTF-8" || media.GetBoundary() != "x" {
+		t.Errorf("got %q, %q, %q", media.Essence(), media.GetCharset(), media.GetBoundary())
+	}
+	if media.String() != "application/vnd.api+json; boundary=x; charset=UTF-8" {
+		t.Errorf("String() = %q", media.String())
+	}
+
+	for _, invalid := range []string{"", "json", "application/", "/json"} {
+		if _, err := ParseContentType(invalid); err == nil {
+			t.Errorf("%q parsed", invalid)
+		}
+	}
+	if media, err := ParseContentType("text/plain; charset"); err != nil || media.Essence() != "text/plain" {
+		t.Errorf("a broken parameter: %+v, %v", media, err)
+	}
+}
+
+func TestContentTypeMatches(t *testing.T) {
+	tests := []struct {
+		pattern ContentType
+		value   string
+		matches bool
+	}{
+		{APPLICATION_JSON, "application/json; charset=utf-8", true},
+		{APPLICATION_JSON, "APPLICATION/JSON", true},
+		{APPLICATION_JSON, "application/problem+json", false},
+		{"application/*+json", "application/problem+json", true},
+		{"application/*+json", "application/json", false},
+		{"application/*+json", "application/xhtml+xml", false},
+		{"application/*", "application/xml", true},
+		{"application/*", "text/xml", false},
+		{WILDCARD, "image/png", true},
+		{"text/plain; charset=utf-8", "text/plain; charset=UTF-8", true},
+		{"text/plain; charset=utf-8", "text/plain", false},
+		{APPLICATION_JSON, "not a type", false},
+		{"not a pattern", "application/json", false},
+	}
+	for _, test := range tests {
+		if matches := test.pattern.Matches(test.value); matches != test.matches {
+			t.Errorf("%s matching %q = %v", test.pattern, test.value, matches)
+		}
+	}
+}
+
+func TestMediaTypeFamilies(t *testing.T) {
+	tests := []struct {
+		value             string
+		json, xml, isText bool
+	}{
+		{"application/json", true, false, false},
+		{"application/problem+json", true, false, false},
+		{"text/json", false, false, true},
+		{"application/xml", false, true, false},
+		{"text/xml", false, true, false},
+		{"image/svg+xml", false, true, false},
+		{"text/html", false, false, true},
+		{"application/octet-stream", false, false, false},
+	}
+	for _, test := range tests {
+		media, err := ParseContentType(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if media.IsJson() != test.json || media.IsXml() != test.xml || media.IsText() != test.isText {
+			t.Errorf("%s: json %v, xml %v, text %v", test.value, media.IsJson(), media.IsXml(), media.IsText())
+		}
+	}
+}
+
+func TestContentTypeWithCharset(t *testing.T) {
+	if contentType := APPLICATION_JSON.WithCharset("utf-8"); contentType != "application/json; charset=utf-8" {
+		t.Errorf("got %q", contentType)
+	}
+	if contentType := ContentType("text/plain; charset=latin1; format=flowed").WithCharset("utf-8"); contentType != "text/plain; charset=utf-8; format=flowed" {
+		t.Errorf("got %q", contentType)
+	}
+	original, _ := ParseContentType("text/plain; charset=latin1")
+	original.WithCharset("utf-8")
+	if original.GetCharset() != "latin1" {
+		t.Error("WithCharset changed the original")
+	}
+	if contentType := ContentType("invalid").WithCharset("utf-8"); contentType != "invalid" {
+		t.Errorf("got %q", contentType)
+	}
+}
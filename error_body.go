@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrEmptyErrorBody = errors.New("fiftyrest: the failure response has no body")
+
+/**
+ * Called by IfFailureWithError with the response and the error of decoding its body, nil when the body was decoded
+ */
+type HttpResponseErrorConsumer func(response HttpResponse, parseErr error)
+
+/**
+ * The problem details of RFC 9457, sent by many APIs as application/problem+json or application/problem+xml.
+ * Members which are not part of the RFC are kept in Extensions, for JSON only.
+ */
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+func (p *ProblemDetails) Error() string {
+	message := "fiftyrest: problem"
+	if p.Status != 0 {
+		message += fmt.Sprintf(" %d", p.Status)
+	}
+	if p.Title != "" {
+		message += " " + p.Title
+	}
+	if p.Detail != "" {
+		message += ": " + p.Detail
+	}
+	return message
+}
+
+func (p *ProblemDetails) UnmarshalJSON(data []byte) error {
+	members := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	*p = ProblemDetails{Type: "about:blank"}
+	// RFC 9457 says members of the wrong type are ignored
+	json.Unmarshal(members["type"], &p.Type)
+	json.Unmarshal(members["title"], &p.Title)
+	json.Unmarshal(members["status"], &p.Status)
+	json.Unmarshal(members["detail"], &p.Detail)
+	json.Unmarshal(members["instance"], &p.Instance)
+	for name, value := range members {
+		switch name {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		var extension interface{}
+		if json.Unmarshal(value, &extension) == nil {
+			if p.Extensions == nil {
+				p.Extensions = make(map[string]interface{})
+			}
+			p.Extensions[name] = extension
+		}
+	}
+	return nil
+}
+
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{})
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	if p.Type != "" {
+		members["type"] = p.Type
+	}
+	if p.Title != "" {
+		members["title"] = p.Title
+	}
+	if p.Status != 0 {
+		members["status"] = p.Status
+	}
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// the members of RFC 9457 Appendix B, in the urn:ietf:rfc:7807 namespace
+type problemXml struct {
+	Type     *string `xml:"type"`
+	Title    string  `xml:"title"`
+	Status   int     `xml:"status"`
+	Detail   string  `xml:"detail"`
+	Instance string  `xml:"instance"`
+}
+
+func (p *ProblemDetails) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var members problemXml
+	if err := decoder.DecodeElement(&members, &start); err != nil {
+		return err
+	}
+	*p = ProblemDetails{Type: "about:blank", Title: members.Title, Status: members.Status, Detail: members.Detail, Instance: members.Instance}
+	if members.Type != nil {
+		p.Type = *members.Type
+	}
+	return nil
+}
+
+func (p *ProblemDetails) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Space: "urn:ietf:rfc:7807", Local: "problem"}
+	var members problemXml
+	if p.Type != "" {
+		members.Type = &p.Type
+	}
+	members.Title, members.Status, members.Detail, members.Instance = p.Title, p.Status, p.Detail, p.Instance
+	return encoder.EncodeElement(members, start)
+}
+
+/**
+ * Decode the problem details of a failure response
+ * @param response the response
+ * @return the problem, nil when the response was a success or neither application/problem+json nor application/problem+xml
+ */
+func MapProblem(response HttpResponse) (*ProblemDetails, error) {
+	if StatusCode(response.GetStatus()).IsSuccess() {
+		return nil, nil
+	}
+	headers := response.GetHeaders()
+	var mapper ObjectMapper
+	switch contentType := headers.GetFirst(CONTENT_TYPE); {
+	case APPLICATION_PROBLEM_JSON.Matches(contentType):
+		mapper = NewJsonObjectMapper()
+	case APPLICATION_PROBLEM_XML.Matches(contentType):
+		mapper = new(XmlObjectMapper)
+	default:
+		return nil, nil
+	}
+	var problem = new(ProblemDetails)
+	if err := mapErrorBody(response, mapper, problem); err != nil {
+		return nil, err
+	}
+	return problem, nil
+}
+
+/**
+ * The implementation of HttpResponse.MapError. Decodes the original body of a failure response into target.
+ * When the body was already mapped into an object the original body is taken from the BodyParseError
+ * of the response, or else the object is encoded again.
+ * @param response the response
+ * @param mapper the ObjectMapper for the Content-Type of the body, see selectErrorMapper
+ * @param target a pointer to the error struct, a *ProblemDetails works for application/problem+json
+ * @return nil when the response was a success or the body was decoded, ErrEmptyErrorBody when there was no body,
+ * or a BodyParseError when the body could not be decoded
+ */
+func mapErrorBody(response HttpResponse, mapper ObjectMapper, target interface{}) error {
+	if response.IsSuccess() {
+		return nil
+	}
+	headers := response.GetHeaders()
+	contentType := headers.GetFirst(CONTENT_TYPE)
+	body, err := originalBody(response, mapper)
+	if err != nil {
+		return &BodyParseError{ContentType: contentType, Cause: err}
+	}
+	if strings.TrimSpace(body) == "" {
+		return ErrEmptyErrorBody
+	}
+	if err := mapper.ReadValue(body, target); err != nil {
+		return &BodyParseError{ContentType: contentType, OriginalBody: body, Cause: err}
+	}
+	return nil
+}
+
+func originalBody(response HttpResponse, mapper ObjectMapper) (string, error) {
+	var parseErr *BodyParseError
+	if errors.As(response.GetParsingError(), &parseErr) && parseErr.OriginalBody != "" {
+		return parseErr.OriginalBody, nil
+	}
+	switch body := response.GetBody().(type) {
+	case nil:
+		return "", nil
+	case string:
+		return body, nil
+	case []byte:
+		return string(body), nil
+	default:
+		return mapper.WriteValue(body)
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// a response with a status, a Content-Type and a body which may already be mapped
+type failureResponse struct {
+	HttpResponse
+	status   int
+	headers  Headers
+	body     interface{}
+	parseErr error
+}
+
+func newFailureResponse(status int, contentType string, body interface{}) *failureResponse {
+	var response = new(failureResponse)
+	response.status = status
+	response.headers = *NewHeaders()
+	if contentType != "" {
+		response.headers.Add(CONTENT_TYPE, contentType)
+	}
+	response.body = body
+	return response
+}
+
+func (r *failureResponse) GetStatus() int         { return r.status }
+func (r *failureResponse) GetHeaders() Headers    { return r.headers }
+func (r *failureResponse) GetBody() interface{}   { return r.body }
+func (r *failureResponse) GetParsingError() error { return r.parseErr }
+func (r *failureResponse) IsSuccess() bool        { return StatusCode(r.status).IsSuccess() }
+
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestMapErrorBodyDecodesTheFailureBody(t *testing.T) {
+	mapper := NewJsonObjectMapper()
+	var target apiError
+	response := newFailureResponse(400, "application/json", `{"code":"invalid","message":"name is missing"}`)
+	if err := mapErrorBody(response, mapper, &target); err != nil || target != (apiError{"invalid", "name is missing"}) {
+		t.Errorf("got %+v, %v", target, err)
+	}
+
+	// the body was mapped into the success type, which dropped the fields of the error
+	target = apiError{}
+	response = newFailureResponse(404, "application/json", map[string]interface{}{"code": "missing"})
+	if err := mapErrorBody(response, mapper, &target); err != nil || target.Code != "missing" {
+		t.Errorf("from a mapped body: %+v, %v", target, err)
+	}
+
+	target = apiError{}
+	response = newFailureResponse(500, "application/json", nil)
+	response.parseErr = &BodyParseError{OriginalBody: `{"code":"boom"}`, Cause: errors.New("not a list")}
+	if err := mapErrorBody(response, mapper, &target); err != nil || target.Code != "boom" {
+		t.Errorf("from the BodyParseError: %+v, %v", target, err)
+	}
+}
+
+func TestMapErrorBodyFailures(t *testing.T) {
+	mapper := NewJsonObjectMapper()
+	var target apiError
+	if err := mapErrorBody(newFailureResponse(200, "application/json", `{"code":"x"}`), mapper, &target); err != nil || target.Code != "" {
+		t.Errorf("a success was mapped: %+v, %v", target, err)
+	}
+	if err := mapErrorBody(newFailureResponse(502, "", " \n"), mapper, &target); !errors.Is(err, ErrEmptyErrorBody) {
+		t.Errorf("empty body: got %v", err)
+	}
+	err := mapErrorBody(newFailureResponse(502, "text/html", "<html>Bad Gateway</html>"), mapper, &target)
+	var parseErr *BodyParseError
+	if !errors.As(err, &parseErr) || parseErr.ContentType != "text/html" || parseErr.OriginalBody != "<html>Bad Gateway</html>" {
+		t.Errorf("html body: got %v", err)
+	}
+}
+
+func TestMapErrorDecodesTheBodyOfAResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(CONTENT_TYPE, string(APPLICATION_PROBLEM_JSON))
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"title":"Bad input","status":400,"code":"E1","message":"name is missing"}`)
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	response := client.Get(server.URL).AsString()
+	var failure apiError
+	if err := response.MapError(&failure); err != nil || failure.Code != "E1" || failure.Message != "name is missing" {
+		t.Errorf("decoded %+v: %v", failure, err)
+	}
+	problem, err := MapProblem(response)
+	if err != nil || problem == nil || problem.Title != "Bad input" || problem.Extensions["code"] != "E1" {
+		t.Errorf("got %+v, %v", problem, err)
+	}
+	var consumed error
+	response.IfFailureWithError(&failure, func(response HttpResponse, parseErr error) { consumed = parseErr })
+	if consumed != nil {
+		t.Errorf("IfFailureWithError got %v", consumed)
+	}
+}
+
+func TestMapProblem(t *testing.T) {
+	body := `{"type":"https://example.com/out-of-credit","title":"Out of credit","status":403,"detail":"balance is 30","balance":30,"instance":7}`
+	problem, err := MapProblem(newFailureResponse(403, "application/problem+json; charset=utf-8", body))
+	if err != nil || problem == nil {
+		t.Fatalf("got %v, %v", problem, err)
+	}
+	if problem.Type != "https://example.com/out-of-credit" || problem.Status != 403 || problem.Detail != "balance is 30" {
+		t.Errorf("got %+v", problem)
+	}
+	if problem.Instance != "" || problem.Extensions["balance"] != float64(30) {
+		t.Errorf("members of the wrong type or extensions: %+v", problem)
+	}
+	if problem.Error() != "fiftyrest: problem 403 Out of credit: balance is 30" {
+		t.Errorf("Error() = %q", problem.Error())
+	}
+
+	problem, err = MapProblem(newFailureResponse(500, "application/problem+json", `{"title":"Oops"}`))
+	if err != nil || problem.Type != "about:blank" {
+		t.Errorf("a missing type: %+v, %v", problem, err)
+	}
+	for _, response := range []*failureResponse{newFailureResponse(200, "application/problem+json", body), newFailureResponse(403, "application/json", body)} {
+		if problem, err := MapProblem(response); problem != nil || err != nil {
+			t.Errorf("status %d %s: got %+v, %v", response.status, response.headers.GetFirst(CONTENT_TYPE), problem, err)
+		}
+	}
+}
+
+func TestProblemDetailsRoundTrip(t *testing.T) {
+	problem := &ProblemDetails{Type: "about:blank", Title: "Conflict", Status: 409, Extensions: map[string]interface{}{"id": "42"}}
+	encoded, err := json.Marshal(problem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded ProblemDetails
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Title != "Conflict" || decoded.Status != 409 || decoded.Extensions["id"] != "42" || len(decoded.Extensions) != 1 {
+		t.Errorf("got %+v from %s", decoded, encoded)
+	}
+}
+
+func TestMapErrorPicksTheMapperByContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/xml" {
+			w.Header().Set(CONTENT_TYPE, string(APPLICATION_PROBLEM_XML))
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `<problem xmlns="urn:ietf:rfc:7807"><title>Out of credit</title><status>403</status></problem>`)
+			return
+		}
+		w.Header().Set(CONTENT_TYPE, string(APPLICATION_JSON))
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"code":"E1","message":"bad input"}`)
+	}))
+	defer server.Close()
+	client := newTestClient(t, NewDefaultConfig())
+
+	var problem ProblemDetails
+	if err := client.Get(server.URL + "/xml").AsString().MapError(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Title != "Out of credit" || problem.Status != 403 || problem.Type != "about:blank" {
+		t.Errorf("decoded %+v", problem)
+	}
+
+	var failure apiError
+	response := client.Get(server.URL + "/json").WithObjectMapper(new(XmlObjectMapper)).AsString()
+	if err := response.MapError(&failure); err != nil || failure.Message != "bad input" {
+		t.Errorf("decoded %+v: %v", failure, err)
+	}
+	var consumed error
+	response.IfFailureWithError(&failure, func(response HttpResponse, parseErr error) { consumed = parseErr })
+	if consumed != nil {
+		t.Errorf("IfFailureWithError got %v", consumed)
+	}
+}
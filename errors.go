@@ -0,0 +1,416 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+/**
+ * Returned when a request was aborted because its context was cancelled or reached its deadline.
+ * A context deadline is reported here and not as a socket timeout, so callers can tell
+ * "the caller gave up" apart from "the server was too slow".
+ * errors.Is(err, context.Canceled) and errors.Is(err, context.DeadlineExceeded) work through Unwrap.
+ */
+type CancelledError struct {
+	Cause   error
+	Request HttpRequestSummary
+}
+
+func (e *CancelledError) Error() string {
+	if e.Request != nil {
+		return fmt.Sprintf("fiftyrest: request %s %s cancelled: %v", e.Request.GetHttpMethod(), e.Request.GetUrl(), e.Cause)
+	}
+	return fmt.Sprintf("fiftyrest: request cancelled: %v", e.Cause)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return false, the caller gave up
+ */
+func (e *CancelledError) Retryable() bool {
+	return false
+}
+
+/**
+ * Replace err with a CancelledError when ctx has ended, or with the TimeoutError which ended it,
+ * since the transport error is then only a symptom of the cancellation.
+ * @param ctx the context of the request
+ * @param err the error returned by the transport
+ * @param request a summary of the request
+ * @return the error to surface to the caller
+ */
+func wrapContextError(ctx context.Context, err error, request HttpRequestSummary) error {
+	if err == nil || ctx.Err() == nil {
+		return err
+	}
+	var timeout *TimeoutError
+	if errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return &CancelledError{Cause: ctx.Err(), Request: request}
+}
+
+type TimeoutPhase string
+
+const (
+	TimeoutPhaseDial           TimeoutPhase = "dial"
+	TimeoutPhaseTLSHandshake   TimeoutPhase = "tls handshake"
+	TimeoutPhaseResponseHeader TimeoutPhase = "response header"
+	TimeoutPhaseIdleRead       TimeoutPhase = "idle read"
+	TimeoutPhaseTotal          TimeoutPhase = "total"
+)
+
+/**
+ * Returned when one of the Timeouts of a request ran out. Phase tells which one.
+ * errors.Is(err, context.DeadlineExceeded) works through Unwrap.
+ */
+type TimeoutError struct {
+	Phase   TimeoutPhase
+	Timeout time.Duration
+	Request HttpRequestSummary
+	Cause   error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Request != nil {
+		return fmt.Sprintf("fiftyrest: %s timeout of %s exceeded for %s %s", e.Phase, e.Timeout, e.Request.GetHttpMethod(), e.Request.GetUrl())
+	}
+	return fmt.Sprintf("fiftyrest: %s timeout of %s exceeded", e.Phase, e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return true, the server may answer in time on another attempt
+ */
+func (e *TimeoutError) Retryable() bool {
+	return true
+}
+
+/**
+ * Implemented by the errors of this package. The retry policy only retries errors reporting true.
+ */
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+/**
+ * @param err an error returned by a request
+ * @return true if err, or an error it wraps, is a RetryableError reporting true
+ */
+func IsRetryable(err error) bool {
+	var retryable RetryableError
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+func describeRequest(request HttpRequestSummary) string {
+	if request == nil {
+		return ""
+	}
+	return fmt.Sprintf(" for %s %s", request.GetHttpMethod(), request.GetUrl())
+}
+
+/**
+ * The host name of the request could not be resolved
+ */
+type DNSError struct {
+	Request HttpRequestSummary
+	Cause   error
+}
+
+func (e *DNSError) Error() string {
+	return fmt.Sprintf("fiftyrest: dns lookup failed%s: %v", describeRequest(e.Request), e.Cause)
+}
+
+func (e *DNSError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return false when the host does not exist, true for temporary resolver failures
+ */
+func (e *DNSError) Retryable() bool {
+	var dns *net.DNSError
+	if errors.As(e.Cause, &dns) {
+		return !dns.IsNotFound && (dns.IsTemporary || dns.IsTimeout)
+	}
+	return false
+}
+
+/**
+ * The connection could not be established, or was reset or refused
+ */
+type ConnectError struct {
+	Request HttpRequestSummary
+	Cause   error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("fiftyrest: connection failed%s: %v", describeRequest(e.Request), e.Cause)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return true, the server may accept a new connection
+ */
+func (e *ConnectError) Retryable() bool {
+	return true
+}
+
+/**
+ * The TLS handshake failed, for example because the certificate was not trusted or did not match a pin
+ */
+type TLSError struct {
+	Request HttpRequestSummary
+	Cause   error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("fiftyrest: tls handshake failed%s: %v", describeRequest(e.Request), e.Cause)
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return false, certificates do not fix themselves between attempts
+ */
+func (e *TLSError) Retryable() bool {
+	return false
+}
+
+/**
+ * The server sent something which is not valid HTTP, or closed the connection half way through the response
+ */
+type ProtocolError struct {
+	Request HttpRequestSummary
+	Cause   error
+}
+
+func (e *ProtocolError) Error() string {
+	return fmt.Sprintf("fiftyrest: protocol error%s: %v", describeRequest(e.Request), e.Cause)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return true, a truncated response is usually a transient failure
+ */
+func (e *ProtocolError) Retryable() bool {
+	return true
+}
+
+/**
+ * The response arrived but its body could not be mapped, see HttpResponse.GetParsingError
+ */
+type BodyParseError struct {
+	Request     HttpRequestSummary
+	ContentType string
+	// the body which could not be parsed, so MapError can still decode it
+	OriginalBody string
+	Cause        error
+}
+
+func (e *BodyParseError) Error() string {
+	if e.ContentType != "" {
+		return fmt.Sprintf("fiftyrest: could not parse %s body%s: %v", e.ContentType, describeRequest(e.Request), e.Cause)
+	}
+	return fmt.Sprintf("fiftyrest: could not parse body%s: %v", describeRequest(e.Request), e.Cause)
+}
+
+func (e *BodyParseError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return false, the same body would fail again
+ */
+func (e *BodyParseError) Retryable() bool {
+	return false
+}
+
+/**
+ * Turn an error of the transport into one of the errors of this package.
+ * Errors which are already classified and errors which match no category are returned as they are.
+ * @param ctx the context the request was sent with
+ * @param err the error of the transport
+ * @param request a summary of the request
+ * @param timeouts the timeouts the request was sent with, for the Timeout of a TimeoutError
+ * @return the classified error
+ */
+func classifyTransportError(ctx context.Context, err error, request HttpRequestSummary, timeouts Timeouts) error {
+	if err == nil {
+		return nil
+	}
+	if wrapped := wrapContextError(ctx, err, request); wrapped != err {
+		return wrapped
+	}
+	var retryable RetryableError
+	if errors.As(err, &retryable) {
+		return err
+	}
+
+	var dns *net.DNSError
+	if errors.As(err, &dns) {
+		return &DNSError{Request: request, Cause: err}
+	}
+	if isTLSFailure(err) {
+		return &TLSError{Request: request, Cause: err}
+	}
+	var op *net.OpError
+	isOp := errors.As(err, &op)
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		phase := TimeoutPhaseTotal
+		switch {
+		case isOp && op.Op == "dial":
+			phase = TimeoutPhaseDial
+		case isOp && op.Op == "read":
+			phase = TimeoutPhaseIdleRead
+		case strings.Contains(err.Error(), "TLS handshake timeout"):
+			phase = TimeoutPhaseTLSHandshake
+		case strings.Contains(err.Error(), "awaiting response headers"):
+			phase = TimeoutPhaseResponseHeader
+		}
+		return &TimeoutError{Phase: phase, Timeout: timeouts.forPhase(phase), Request: request, Cause: err}
+	}
+	if isOp {
+		return &ConnectError{Request: request, Cause: err}
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) || strings.Contains(err.Error(), "malformed HTTP") {
+		return &ProtocolError{Request: request, Cause: err}
+	}
+	return err
+}
+
+func isTLSFailure(err error) bool {
+	var verification *tls.CertificateVerificationError
+	var record tls.RecordHeaderError
+	var alert tls.AlertError
+	var authority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	return errors.As(err, &verification) || errors.As(err, &record) || errors.As(err, &alert) ||
+		errors.As(err, &authority) || errors.As(err, &hostname) || errors.As(err, &invalid) ||
+		errors.Is(err, ErrPinMismatch)
+}
+
+const DEFAULT_STATUS_ERROR_SNIPPET = 512
+
+/**
+ * A response whose status was not the expected one, by default anything outside 2xx
+ */
+type StatusError struct {
+	Status     StatusCode
+	StatusText string
+	// the codes set with HttpRequest.ExpectStatus, empty when any 2xx status was expected
+	Expected []int
+	// the start of the body, at most DEFAULT_STATUS_ERROR_SNIPPET bytes
+	Body     string
+	Response HttpResponse
+	Request  HttpRequestSummary
+}
+
+/**
+ * Turn a response which was not a 2xx response into an error
+ * @param response the response
+ * @param request a summary of the request, may be nil
+ * @return a StatusError, or nil for 2xx responses
+ */
+func NewStatusError(response HttpResponse, request HttpRequestSummary) error {
+	if StatusCode(response.GetStatus()).IsSuccess() {
+		return nil
+	}
+	return newStatusError(response, request)
+}
+
+func newStatusError(response HttpResponse, request HttpRequestSummary) *StatusError {
+	var err = new(StatusError)
+	err.Status = StatusCode(response.GetStatus())
+	err.StatusText = response.GetStatusText()
+	if err.StatusText == "" {
+		err.StatusText = err.Status.Text()
+	}
+	err.Body = bodySnippet(response.GetBody(), DEFAULT_STATUS_ERROR_SNIPPET)
+	err.Response = response
+	err.Request = request
+	return err
+}
+
+/**
+ * Check the status of a response against HttpRequest.ExpectStatus, or Config.ErrorOnFailureStatus when no status was expected
+ * @param config the config
+ * @param expected the status codes expected by the request
+ * @param response the response
+ * @param request a summary of the request
+ * @return a StatusError carrying the response, or nil when the status is fine
+ */
+func checkStatus(config *Config, expected []int, response HttpResponse, request HttpRequestSummary) error {
+	if len(expected) > 0 {
+		for _, code := range expected {
+			if response.GetStatus() == code {
+				return nil
+			}
+		}
+		err := newStatusError(response, request)
+		err.Expected = expected
+		return err
+	}
+	if config.ErrorOnFailureStatus {
+		return NewStatusError(response, request)
+	}
+	return nil
+}
+
+func (e *StatusError) Error() string {
+	message := fmt.Sprintf("fiftyrest: unexpected status %d", int(e.Status))
+	if e.StatusText != "" {
+		message += " " + e.StatusText
+	}
+	if len(e.Expected) > 0 {
+		message += fmt.Sprintf(", expected %v", e.Expected)
+	}
+	message += describeRequest(e.Request)
+	if e.Body != "" {
+		message += ": " + e.Body
+	}
+	return message
+}
+
+/**
+ * @return true for 408, 429, 502, 503 and 504, which usually go away by themselves
+ */
+func (e *StatusError) Retryable() bool {
+	switch e.Status {
+	case REQUEST_TIMEOUT, TOO_MANY_REQUESTS, BAD_GATEWAY, SERVICE_UNAVAILABLE, GATEWAY_TIMEOUT:
+		return true
+	}
+	return false
+}
+
+func bodySnippet(body interface{}, max int) string {
+	text, dropped := truncateText(strings.TrimSpace(bodyText(body)), max)
+	if dropped > 0 {
+		text += "..."
+	}
+	return text
+}
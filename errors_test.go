@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWrapContextErrorReportsTheCaller(t *testing.T) {
+	transportErr := errors.New("read: connection reset")
+	if err := wrapContextError(context.Background(), transportErr, nil); err != transportErr {
+		t.Errorf("a live context changed the error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := wrapContextError(ctx, transportErr, nil)
+	var cancelled *CancelledError
+	if !errors.As(err, &cancelled) || !errors.Is(err, context.Canceled) || IsRetryable(err) {
+		t.Errorf("got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	if err := wrapContextError(ctx, transportErr, nil); !errors.Is(err, context.DeadlineExceeded) || !errors.As(err, &cancelled) {
+		t.Errorf("a deadline of the caller was not a CancelledError: %v", err)
+	}
+}
+
+func TestWrapContextErrorKeepsTheTimeoutWhichEndedTheContext(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(&TimeoutError{Phase: TimeoutPhaseTotal, Timeout: time.Second, Cause: context.DeadlineExceeded})
+	err := wrapContextError(ctx, io.ErrUnexpectedEOF, nil)
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Phase != TimeoutPhaseTotal || !IsRetryable(err) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestClassifyTransportError(t *testing.T) {
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	cases := []struct {
+		err       error
+		target    interface{}
+		retryable bool
+	}{
+		{&url.Error{Op: "Get", URL: "http://api.invalid", Err: &net.DNSError{Err: "no such host", Name: "api.invalid", IsNotFound: true}}, new(*DNSError), false},
+		{&net.DNSError{Err: "server misbehaving", Name: "api", IsTemporary: true}, new(*DNSError), true},
+		{&url.Error{Op: "Get", URL: "http://api", Err: dial}, new(*ConnectError), true},
+		{&url.Error{Op: "Get", URL: "https://api", Err: x509.UnknownAuthorityError{}}, new(*TLSError), false},
+		{&url.Error{Op: "Get", URL: "http://api", Err: io.ErrUnexpectedEOF}, new(*ProtocolError), true},
+		{&url.Error{Op: "Get", URL: "http://api", Err: &net.OpError{Op: "dial", Err: timeoutErr{}}}, new(*TimeoutError), true},
+	}
+	for _, c := range cases {
+		err := classifyTransportError(context.Background(), c.err, nil, Timeouts{})
+		if !errors.As(err, c.target) {
+			t.Errorf("%v classified as %T", c.err, err)
+		}
+		if IsRetryable(err) != c.retryable {
+			t.Errorf("%T: Retryable() = %v", err, !c.retryable)
+		}
+		if !errors.Is(err, c.err) {
+			t.Errorf("%T does not unwrap to the transport error", err)
+		}
+	}
+
+	var timeout *TimeoutError
+	err := classifyTransportError(context.Background(), &net.OpError{Op: "dial", Err: timeoutErr{}}, nil, Timeouts{Dial: time.Second, IdleRead: time.Minute})
+	if !errors.As(err, &timeout) || timeout.Phase != TimeoutPhaseDial || timeout.Timeout != time.Second {
+		t.Errorf("got %v", err)
+	}
+	other := errors.New("something else")
+	if err := classifyTransportError(context.Background(), other, nil, Timeouts{}); err != other {
+		t.Errorf("unknown error changed: %v", err)
+	}
+	if classifyTransportError(context.Background(), nil, nil, Timeouts{}) != nil {
+		t.Error("nil became an error")
+	}
+}
+
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o timeout" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
@@ -1 +1,12 @@
 package main
+
+const (
+	VERSION            = "0.1.0"
+	DEFAULT_USER_AGENT = "fiftyrest/" + VERSION
+)
+
+/**
+ * fiftyrest is used as a library. The package is called main, which needs a main function to build
+ */
+func main() {
+}
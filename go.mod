@@ -1,3 +1,16 @@
 module github.com/kairatbmstu/fiftyrest
 
-go 1.16
+go 1.23
+
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/fxamacker/cbor/v2 v2.7.0
+	github.com/klauspost/compress v1.17.11
+	golang.org/x/text v0.22.0
+	software.sslmate.com/src/go-pkcs12 v0.5.0
+)
+
+require (
+	github.com/x448/float16 v0.8.4 // indirect
+	golang.org/x/crypto v0.31.0 // indirect
+)
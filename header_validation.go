@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+var (
+	ErrInvalidHeaderName  = errors.New("fiftyrest: invalid header name")
+	ErrInvalidHeaderValue = errors.New("fiftyrest: invalid header value")
+	ErrHopByHopHeader     = errors.New("fiftyrest: hop-by-hop header")
+)
+
+/**
+ * A header which may not be sent. Unwraps to ErrInvalidHeaderName, ErrInvalidHeaderValue or ErrHopByHopHeader
+ */
+type HeaderError struct {
+	Name   string
+	Reason string
+	Cause  error
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("%v %q: %s", e.Cause, e.Name, e.Reason)
+}
+
+func (e *HeaderError) Unwrap() error {
+	return e.Cause
+}
+
+/**
+ * @return false, the same header fails again
+ */
+func (e *HeaderError) Retryable() bool {
+	return false
+}
+
+/**
+ * Headers which only concern a single connection. The transport manages them, setting them by hand
+ * breaks the connection handling or smuggles requests past proxies.
+ */
+var hopByHopHeaders = map[string]bool{
+	"connection":        true,
+	"te":                true,
+	"trailer":           true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+}
+
+/**
+ * Headers net/http writes or inspects itself under their canonical key. Sent with another spelling
+ * they would go out a second time next to the transport's own, or slip past its checks
+ */
+var transportHeaders = map[string]bool{
+	"host":                true,
+	"user-agent":          true,
+	"content-length":      true,
+	"transfer-encoding":   true,
+	"trailer":             true,
+	"connection":          true,
+	"keep-alive":          true,
+	"proxy-connection":    true,
+	"upgrade":             true,
+	"te":                  true,
+	"expect":              true,
+	"accept-encoding":     true,
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"referer":             true,
+}
+
+/**
+ * Names which textproto.CanonicalMIMEHeaderKey gets wrong
+ */
+var canonicalExceptions = map[string]string{
+	"etag":             ETAG,
+	"te":               TE,
+	"www-authenticate": WWW_AUTHENTICATE,
+	"content-md5":      "Content-MD5",
+	"dnt":              "DNT",
+	"x-xss-protection": "X-XSS-Protection",
+}
+
+/**
+ * @param name a header name
+ * @return true for Connection, TE, Trailer, Transfer-Encoding, Upgrade, Keep-Alive and Proxy-Connection
+ */
+func IsHopByHopHeader(name string) bool {
+	return hopByHopHeaders[strings.ToLower(name)]
+}
+
+/**
+ * Check a header name is a token as RFC 9110 defines it
+ * @param name the header name
+ * @return a HeaderError wrapping ErrInvalidHeaderName, or nil
+ */
+func ValidateHeaderName(name string) error {
+	if name == "" {
+		return &HeaderError{Name: name, Reason: "empty name", Cause: ErrInvalidHeaderName}
+	}
+	for i := 0; i < len(name); i++ {
+		if !isTokenChar(name[i]) {
+			return &HeaderError{Name: name, Reason: fmt.Sprintf("character %q at position %d is not allowed", name[i], i), Cause: ErrInvalidHeaderName}
+		}
+	}
+	return nil
+}
+
+/**
+ * Check a header value holds only visible characters, spaces, tabs and obs-text as RFC 9110 defines it.
+ * CR and LF are rejected, they would let the value inject headers.
+ * @param name the header name, for the error
+ * @param value the header value
+ * @return a HeaderError wrapping ErrInvalidHeaderValue, or nil
+ */
+func ValidateHeaderValue(name string, value string) error {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == '\r' || c == '\n':
+			return &HeaderError{Name: name, Reason: fmt.Sprintf("line break at position %d", i), Cause: ErrInvalidHeaderValue}
+		case c == '\t' || c >= 0x20 && c != 0x7f:
+		default:
+			return &HeaderError{Name: name, Reason: fmt.Sprintf("control character %q at position %d", c, i), Cause: ErrInvalidHeaderValue}
+		}
+	}
+	return nil
+}
+
+func CanonicalHeaderName(name string) string {
+	if exception, ok := canonicalExceptions[strings.ToLower(name)]; ok {
+		return exception
+	}
+	return textproto.CanonicalMIMEHeaderKey(name)
+}
+
+/**
+ * Check every header of a request before it is sent
+ * @param headers the headers of the request
+ * @param allowHopByHop Config.AllowHopByHopHeaders
+ * @param allowed hop-by-hop headers the request allowed with HttpRequest.AllowHopByHop
+ * @return the HeaderErrors of all bad headers joined, or nil
+ */
+func ValidateHeaders(headers Headers, allowHopByHop bool, allowed ...string) error {
+	errs := make([]error, 0)
+	for _, header := range headers.Headers {
+		name := header.GetName()
+		if err := ValidateHeaderName(name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := ValidateHeaderValue(name, header.GetValue()); err != nil {
+			errs = append(errs, err)
+		}
+		if IsHopByHopHeader(name) && !allowHopByHop && !containsFold(allowed, name) {
+			errs = append(errs, &HeaderError{Name: name, Reason: "set by the transport, allow it with HttpRequest.AllowHopByHop", Cause: ErrHopByHopHeader})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+/**
+ * Validate the headers and turn them into the headers of the net/http request. Suppliers are called here, once.
+ * The names keep the case they were added with unless Config.CanonicalizeHeaderNames is set,
+ * except the headers net/http manages which always get the key net/http looks them up by; names differing only in case
+ * are sent under the first spelling.
+ * @param config the config
+ * @param headers the headers of the request
+ * @param allowed hop-by-hop headers the request allowed with HttpRequest.AllowHopByHop
+ * @return the headers to send, or the error of a supplier or the errors of ValidateHeaders
+ */
+func wireHeaders(config *Config, headers Headers, allowed ...string) (http.Header, error) {
+	resolved, err := headers.resolve()
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateHeaders(resolved, config.AllowHopByHopHeaders, allowed...); err != nil {
+		return nil, err
+	}
+	wire := make(http.Header, len(resolved.Headers))
+	spelling := make(map[string]string)
+	for _, header := range resolved.Headers {
+		name := header.GetName()
+		key := strings.ToLower(name)
+		if transportHeaders[key] {
+			name = textproto.CanonicalMIMEHeaderKey(name)
+		} else if config.CanonicalizeHeaderNames {
+			name = CanonicalHeaderName(name)
+		} else if first, ok := spelling[key]; ok {
+			name = first
+		} else {
+			spelling[key] = name
+		}
+		wire[name] = append(wire[name], strings.TrimSpace(header.GetValue()))
+	}
+	return wire, nil
+}
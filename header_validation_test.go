@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateHeaders(t *testing.T) {
+	headers := NewHeaders()
+	headers.Add("X-Ok", "fine\tvalue")
+	headers.Add("Bad Name", "x")
+	headers.Add("X-Injected", "a\r\nHost: evil")
+	headers.Add("connection", "close")
+
+	err := ValidateHeaders(*headers, false)
+	for _, want := range []error{ErrInvalidHeaderName, ErrInvalidHeaderValue, ErrHopByHopHeader} {
+		if !errors.Is(err, want) {
+			t.Errorf("%v missing from %v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "X-Ok") {
+		t.Errorf("valid header reported: %v", err)
+	}
+	if err := ValidateHeaders(*headers, false, "Bad Name", "X-Injected", "Connection"); !errors.Is(err, ErrInvalidHeaderName) || errors.Is(err, ErrHopByHopHeader) {
+		t.Errorf("allowing the hop-by-hop header: %v", err)
+	}
+}
+
+func TestCanonicalHeaderName(t *testing.T) {
+	cases := map[string]string{"content-type": "Content-Type", "etag": "ETag", "www-authenticate": "WWW-Authenticate", "x-request-id": "X-Request-Id"}
+	for name, want := range cases {
+		if got := CanonicalHeaderName(name); got != want {
+			t.Errorf("CanonicalHeaderName(%s) = %s", name, got)
+		}
+	}
+}
+
+func TestWireHeadersKeepsCaseExceptForTransportHeaders(t *testing.T) {
+	var config = NewDefaultConfig()
+	headers := NewHeaders()
+	headers.Add("user-agent", "custom")
+	headers.Add("x-Request-ID", "1")
+	headers.Add("X-REQUEST-ID", "2")
+	headers.Add("content-length", "3")
+
+	wire, err := wireHeaders(config, *headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wire["x-Request-ID"]; len(got) != 2 {
+		t.Errorf("custom header lost its spelling: %v", wire)
+	}
+	if got := wire["User-Agent"]; len(got) != 1 || got[0] != "custom" {
+		t.Errorf("User-Agent not canonical: %v", wire)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.Header = wire
+	var sent bytes.Buffer
+	if err := request.Write(&sent); err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	lines := bufio.NewScanner(&sent)
+	for lines.Scan() {
+		if name, _, ok := strings.Cut(lines.Text(), ":"); ok {
+			counts[strings.ToLower(name)]++
+		}
+	}
+	for _, name := range []string{"user-agent", "content-length", "host"} {
+		if counts[name] != 1 {
+			t.Errorf("%s sent %d times:\n%s", name, counts[name], sent.String())
+		}
+	}
+}
+
+func TestWireHeadersCallsSuppliersOnce(t *testing.T) {
+	var config = NewDefaultConfig()
+	config.CanonicalizeHeaderNames = true
+	calls := 0
+	headers := NewHeaders()
+	headers.AddSupplier("authorization", func() (string, error) {
+		calls++
+		return "Bearer token", nil
+	})
+	headers.AddSupplier("X-Empty", func() (string, error) { return "", nil })
+
+	wire, err := wireHeaders(config, *headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("supplier called %d times", calls)
+	}
+	if wire.Get(AUTHORIZATION) != "Bearer token" || len(wire) != 1 {
+		t.Errorf("got %v", wire)
+	}
+
+	failing := errors.New("token service down")
+	headers.AddSupplier("X-Broken", func() (string, error) { return "", failing })
+	if _, err := wireHeaders(config, *headers); !errors.Is(err, failing) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -101,6 +101,9 @@ const (
 	/** RFC 1945 (HTTP/1.0) Section 10.10, RFC 2616 (HTTP/1.1) Section 14.29 */
 	LAST_MODIFIED = "Last-Modified"
 
+	/** RFC 8288 (Web Linking) Section 3 */
+	LINK = "Link"
+
 	/** RFC 1945 (HTTP/1.0) Section 10.11, RFC 2616 (HTTP/1.1) Section 14.30 */
 	LOCATION = "Location"
 
@@ -134,6 +137,9 @@ const (
 	/** RFC 1945 (HTTP/1.0) Section 10.14, RFC 2616 (HTTP/1.1) Section 14.38 */
 	SERVER = "Server"
 
+	/** RFC 6265 Section 4.1 */
+	SET_COOKIE = "Set-Cookie"
+
 	/** RFC 2518 (WevDAV) Section 9.7 */
 	STATUS_URI = "Status-URI"
 
@@ -1,5 +1,10 @@
 package main
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Headers struct {
 	Headers []Header
 }
@@ -9,148 +14,288 @@ type Entry struct {
 	Value string
 }
 
-
-
 func NewHeaders() *Headers {
 	var headers = new(Headers)
 	headers.Headers = make([]Header, 0)
 	return headers
 }
 
-func NewEntry(name string, value string){
+func NewEntry(name string, value string) Entry {
 	var entry Entry
 	entry.Name = name
 	entry.Value = value
+	return entry
+}
+
+func (e Entry) GetName() string {
+	return e.Name
+}
+
+func (e Entry) GetValue() string {
+	return e.Value
+}
+
+func (e Entry) String() string {
+	return e.Name + ": " + e.Value
+}
+
+/**
+ * Supplies a header value each time a request is sent, e.g. a short lived auth token.
+ * An empty value leaves the header out
+ */
+type HeaderSupplier func() (string, error)
+
+/**
+ * A header whose value comes from a HeaderSupplier
+ */
+type SupplierEntry struct {
+	Name     string
+	Supplier HeaderSupplier
+}
+
+func (e SupplierEntry) GetName() string {
+	return e.Name
+}
+
+/**
+ * @return "", the supplier is only called when the request is sent so that reading or logging
+ * the headers never triggers it. Its errors fail the request
+ */
+func (e SupplierEntry) GetValue() string {
+	return ""
+}
+
+/**
+ * Add a header element
+ * @param name the name of the header
+ * @param value the value for the header
+ */
+func (h *Headers) Add(name string, value string) {
+	if name != "" {
+		h.appendHeaders(NewEntry(name, value))
+	}
+}
+
+/**
+ * Add a header whose value is supplied when the request is sent
+ * @param name the name of the header
+ * @param supplier supplies the value
+ */
+func (h *Headers) AddSupplier(name string, supplier HeaderSupplier) {
+	if name != "" && supplier != nil {
+		h.appendHeaders(SupplierEntry{Name: name, Supplier: supplier})
+	}
+}
+
+/**
+ * Replace a header with one whose value is supplied when the request is sent
+ * @param name the name of the header
+ * @param supplier supplies the value
+ */
+func (h *Headers) ReplaceSupplier(name string, supplier HeaderSupplier) {
+	h.remove(name)
+	h.AddSupplier(name, supplier)
+}
+
+/**
+ * Replace a header value. If there are multiple instances it will overwrite all of them
+ * @param name the name of the header
+ * @param value the value for the header
+ */
+func (h *Headers) Replace(name string, value string) {
+	h.remove(name)
+	h.Add(name, value)
+}
+
+func (h *Headers) remove(name string) {
+	kept := make([]Header, 0, len(h.Headers))
+	for _, header := range h.Headers {
+		if !isName(header, name) {
+			kept = append(kept, header)
+		}
+	}
+	h.Headers = kept
+}
+
+/**
+ * Get the number of header keys.
+ * @return the size of the header keys
+ */
+func (h *Headers) Size() int {
+	return len(h.Names())
 }
 
+/**
+ * Get the distinct header names in the order they were first added
+ * @return the header names
+ */
+func (h *Headers) Names() []string {
+	names := make([]string, 0, len(h.Headers))
+	seen := make(map[string]bool)
+	for _, header := range h.Headers {
+		key := strings.ToLower(header.GetName())
+		if !seen[key] {
+			seen[key] = true
+			names = append(names, header.GetName())
+		}
+	}
+	return names
+}
+
+/**
+ * Get all the values for a header name
+ * @param name name of the header element
+ * @return a list of values
+ */
+func (h *Headers) Get(name string) []string {
+	values := make([]string, 0)
+	for _, header := range h.Headers {
+		if isName(header, name) {
+			values = append(values, header.GetValue())
+		}
+	}
+	return values
+}
+
+/**
+ * Add a bunch of headers at once
+ * @param header a header
+ */
+func (h *Headers) PutAll(header Headers) {
+	h.appendHeaders(header.Headers...)
+}
+
+/**
+ * Check if a header is present
+ * @param name a header
+ * @return if the headers contain this name.
+ */
+func (h *Headers) ContainsKey(name string) bool {
+	for _, header := range h.Headers {
+		if isName(header, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Clear the headers!
+ */
+func (h *Headers) Clear() {
+	h.Headers = make([]Header, 0)
+}
+
+/**
+ * Get the first header value for a name
+ * @param key the name of the header
+ * @return the first value
+ */
+func (h *Headers) GetFirst(key string) string {
+	for _, header := range h.Headers {
+		if isName(header, key) {
+			return header.GetValue()
+		}
+	}
+	return ""
+}
+
+/**
+ * Get all of the headers
+ * @return all the headers, in order
+ */
+func (h *Headers) All() []Header {
+	all := make([]Header, len(h.Headers))
+	copy(all, h.Headers)
+	return all
+}
+
+/**
+ * Append into a new array. Headers is copied by value, in Config, in responses and for interceptors,
+ * and the copies must not write into the array they share
+ */
+func (h *Headers) appendHeaders(headers ...Header) {
+	grown := make([]Header, len(h.Headers), len(h.Headers)+len(headers))
+	copy(grown, h.Headers)
+	h.Headers = append(grown, headers...)
+}
+
+func isName(h Header, name string) bool {
+	return strings.EqualFold(name, h.GetName())
+}
+
+func (h *Headers) removeValue(key string, value string) {
+	kept := make([]Header, 0, len(h.Headers))
+	for _, header := range h.Headers {
+		if !(strings.EqualFold(key, header.GetName()) && strings.EqualFold(value, header.GetValue())) {
+			kept = append(kept, header)
+		}
+	}
+	h.Headers = kept
+}
+
+/**
+ * @return list all headers like this: <pre>Content-Length: 42
+ * Cache-Control: no-cache
+ * ...</pre>
+ */
+func (h Headers) String() string {
+	lines := make([]string, 0, len(h.Headers))
+	for _, header := range h.Headers {
+		lines = append(lines, header.GetName()+": "+header.GetValue())
+	}
+	return strings.Join(lines, "\n")
+}
 
-    /**
-     * Add a header element
-     * @param name the name of the header
-     * @param value the value for the header
-     */
-	 public (h *Headers) void add(String name, String value) {
-        add(name, () -> value);
-    }
-
-    /**
-     * Add a header element with a supplier which will be evaluated on request
-     * @param name the name of the header
-     * @param value the value for the header
-     */
-    public void add(String name, Supplier<String> value) {
-        if (Objects.nonNull(name)) {
-            headers.add(new Entry(name, value));
-        }
-    }
-
-    /**
-     * Replace a header value. If there are multiple instances it will overwrite all of them
-     * @param name the name of the header
-     * @param value the value for the header
-     */
-    public void replace(String name, String value) {
-        remove(name);
-        add(name, value);
-    }
-
-    private void remove(String name) {
-        headers.removeIf(h -> isName(h, name));
-    }
-
-    /**
-     * Get the number of header keys.
-     * @return the size of the header keys
-     */
-    public int size() {
-        return headers.stream().map(Header::getName).collect(toSet()).size();
-    }
-
-    /**
-     * Get all the values for a header name
-     * @param name name of the header element
-     * @return a list of values
-     */
-    public List<String> get(String name) {
-        return headers.stream()
-                .filter(h -> isName(h, name))
-                .map(Header::getValue)
-                .collect(toList());
-    }
-
-    /**
-     * Add a bunch of headers at once
-     * @param header a header
-     */
-    public void putAll(Headers header) {
-        this.headers.addAll(header.headers);
-    }
-
-    /**
-     * Check if a header is present
-     * @param name a header
-     * @return if the headers contain this name.
-     */
-    public boolean containsKey(String name) {
-        return this.headers.stream().anyMatch(h -> isName(h, name));
-    }
-
-    /**
-     * Clear the headers!
-     */
-    public void clear() {
-        this.headers.clear();
-    }
-
-    /**
-     * Get the first header value for a name
-     * @param key the name of the header
-     * @return the first value
-     */
-    public String getFirst(String key) {
-        return headers
-                .stream()
-                .filter(h -> isName(h, key))
-                .findFirst()
-                .map(Header::getValue)
-                .orElse("");
-    }
-
-    /**
-     * Get all of the headers
-     * @return all the headers, in order
-     */
-    public List<Header> all() {
-        return new ArrayList<>(this.headers);
-    }
-
-    private boolean isName(Header h, String name) {
-        return Util.nullToEmpty(name).equalsIgnoreCase(h.getName());
-    }
-
-    void remove(String key, String value) {
-        List<Header> header = headers.stream().
-                filter(h -> key.equalsIgnoreCase(h.getName()) && value.equalsIgnoreCase(h.getValue()))
-                .collect(toList());
-        headers.removeAll(header);
-    }
-
-    /**
-     * @return list all headers like this: <pre>Content-Length: 42
-     * Cache-Control: no-cache
-     * ...</pre>
-     */
-    @Override
-    public String toString() {
-       final StringJoiner sb = new StringJoiner(System.lineSeparator());
-        headers.forEach(header -> sb.add(header.toString()));
-        return sb.toString();
-    }
-
-    public void cookie(Cookie cookie) {
-        headers.add(new Entry("cookie", cookie.toString()));
-    }
-
-    public void cookie(Collection<Cookie> cookies) {
-        cookies.forEach(this::cookie);
-    }
+func (h *Headers) Cookie(cookie Cookie) {
+	h.appendHeaders(NewEntry("cookie", cookie.Name+"="+cookie.value))
+}
+
+func (h *Headers) CookieAll(cookies []Cookie) {
+	for _, cookie := range cookies {
+		h.Cookie(cookie)
+	}
+}
+
+/**
+ * Call the suppliers once and return plain entries. Headers whose supplier returns "" are left out
+ * @return the headers with fixed values, or the first error of a supplier
+ */
+func (h *Headers) resolve() (Headers, error) {
+	var resolved Headers
+	resolved.Headers = make([]Header, 0, len(h.Headers))
+	for _, header := range h.Headers {
+		supplied, ok := header.(SupplierEntry)
+		if !ok {
+			resolved.Headers = append(resolved.Headers, header)
+			continue
+		}
+		value, err := supplied.Supplier()
+		if err != nil {
+			return Headers{}, fmt.Errorf("fiftyrest: supplying header %s: %w", supplied.Name, err)
+		}
+		if value != "" {
+			resolved.Headers = append(resolved.Headers, NewEntry(supplied.Name, value))
+		}
+	}
+	return resolved, nil
+}
+
+/**
+ * Merge the default headers of the config into the headers of a request. A header set on the request,
+ * with Header or HeaderReplace, replaces every default value of that name. Suppliers are evaluated here, once per request
+ * @param defaults Config.DefaultHeaders
+ * @param request the headers of the request
+ * @return the headers to send, or the error of a supplier
+ */
+func mergeDefaultHeaders(defaults Headers, request Headers) (Headers, error) {
+	var merged Headers
+	merged.Headers = make([]Header, 0, len(defaults.Headers)+len(request.Headers))
+	for _, header := range defaults.Headers {
+		if !request.ContainsKey(header.GetName()) {
+			merged.Headers = append(merged.Headers, header)
+		}
+	}
+	merged.Headers = append(merged.Headers, request.Headers...)
+	return merged.resolve()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func headerLines(h Headers) []string {
+	lines := make([]string, 0)
+	for _, header := range h.Headers {
+		lines = append(lines, header.GetName()+": "+header.GetValue())
+	}
+	return lines
+}
+
+func TestHeadersNamesAndValues(t *testing.T) {
+	headers := NewHeaders()
+	headers.Add("Accept", "text/plain")
+	headers.Add("X-Trace", "1")
+	headers.Add("accept", "application/json")
+
+	if got := headers.Names(); !reflect.DeepEqual(got, []string{"Accept", "X-Trace"}) {
+		t.Errorf("Names() = %v", got)
+	}
+	if got := headers.Get("ACCEPT"); !reflect.DeepEqual(got, []string{"text/plain", "application/json"}) {
+		t.Errorf("Get() = %v", got)
+	}
+	if headers.GetFirst("x-trace") != "1" || headers.Size() != 2 {
+		t.Errorf("GetFirst() = %q, Size() = %d", headers.GetFirst("x-trace"), headers.Size())
+	}
+
+	headers.Replace("Accept", "*/*")
+	if got := headerLines(*headers); !reflect.DeepEqual(got, []string{"X-Trace: 1", "Accept: */*"}) {
+		t.Errorf("after Replace %v", got)
+	}
+}
+
+func TestHeadersCopiesDoNotShareWrites(t *testing.T) {
+	original := NewHeaders()
+	original.Add("Accept", "text/plain")
+	original.Add("X-Trace", "1")
+	original.Add("User-Agent", "fiftyrest")
+
+	copied := *original
+	original.Replace("Accept", "application/json")
+	original.removeValue("X-Trace", "1")
+	want := []string{"Accept: text/plain", "X-Trace: 1", "User-Agent: fiftyrest"}
+	if got := headerLines(copied); !reflect.DeepEqual(got, want) {
+		t.Fatalf("copy changed by remove: %v", got)
+	}
+
+	original.Clear()
+	original.Add("X-Other", "2")
+	if got := headerLines(copied); !reflect.DeepEqual(got, want) {
+		t.Fatalf("copy changed by Clear and Add: %v", got)
+	}
+
+	first, second := copied, copied
+	first.Add("X-First", "1")
+	second.Add("X-Second", "2")
+	if first.GetFirst("X-First") != "1" || first.ContainsKey("X-Second") {
+		t.Fatalf("appends to copies overwrote each other: %v", headerLines(first))
+	}
+}
+
+func TestConfigCopyKeepsDefaultHeaders(t *testing.T) {
+	config := NewDefaultConfig()
+	config.AddDefaultHeader("Accept", "application/json")
+	config.AddDefaultHeader("X-Trace", "1")
+	copied := *config
+	config.SetDefaultHeader(USER_AGENT, "mine/1")
+
+	if got := copied.DefaultHeaders.GetFirst(USER_AGENT); got != DEFAULT_USER_AGENT {
+		t.Fatalf("copy has User-Agent %q", got)
+	}
+}
+
+func TestSuppliersRunOnlyWhenMerged(t *testing.T) {
+	calls := 0
+	var defaults Headers
+	defaults.Add(USER_AGENT, "fiftyrest")
+	defaults.AddSupplier(AUTHORIZATION, func() (string, error) {
+		calls++
+		return "Bearer token", nil
+	})
+	_ = defaults.Get(AUTHORIZATION)
+	_ = defaults.GetFirst(AUTHORIZATION)
+	_ = defaults.String()
+	if calls != 0 {
+		t.Fatalf("reading the headers called the supplier %d times", calls)
+	}
+
+	var request Headers
+	request.Add("user-agent", "custom")
+	merged, err := mergeDefaultHeaders(defaults, request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("supplier called %d times", calls)
+	}
+	if got := headerLines(merged); !reflect.DeepEqual(got, []string{"Authorization: Bearer token", "user-agent: custom"}) {
+		t.Errorf("got %v", got)
+	}
+
+	failing := errors.New("token service down")
+	defaults.ReplaceSupplier(AUTHORIZATION, func() (string, error) { return "", failing })
+	if _, err := mergeDefaultHeaders(defaults, request); !errors.Is(err, failing) {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,280 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"time"
+)
+
+/**
+ * The Client of this package, sending requests with net/http. Close comes from the embedded ClientLifecycle
+ */
+type HttpClient struct {
+	*ClientLifecycle
+	config *Config
+	client *http.Client
+}
+
+/**
+ * Create a client with the transport newTransport builds from the config
+ * @param config the config
+ * @return the client, or an error if the TLS settings of the config are invalid
+ */
+func NewHttpClient(config *Config) (*HttpClient, error) {
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	return NewHttpClientWithTransport(config, transport), nil
+}
+
+/**
+ * Create a client sending requests through a transport of the caller's own. An *http.Transport must
+ * have DisableCompression set, see HttpRequest.DecompressResponse. Timeouts and connection pool
+ * statistics rely on httptrace, which transports that do not dial through net/http may not report
+ * @param config the config
+ * @param transport the transport
+ * @return the client
+ */
+func NewHttpClientWithTransport(config *Config, transport http.RoundTripper) *HttpClient {
+	var client = new(HttpClient)
+	client.ClientLifecycle = NewClientLifecycle(config)
+	client.config = config
+	client.client = new(http.Client)
+	client.client.Transport = transport
+	if !config.FollowRedirects {
+		client.client.CheckRedirect = func(request *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	if config.CookieManagement {
+		client.client.Jar, _ = cookiejar.New(nil)
+	}
+	return client
+}
+
+/**
+ * @return the *http.Client requests are sent with
+ */
+func (c *HttpClient) GetClient() interface{} {
+	return c.client
+}
+
+/**
+ * Execute the request with its own context, see RequestWithContext
+ */
+func (c *HttpClient) Request(request HttpRequest, httpResponse RawResponseToHttpResponseTransformer) (HttpResponse, error) {
+	return c.RequestWithContext(request.GetContext(), request, httpResponse)
+}
+
+/**
+ * Execute the request, passing it through the interceptors of the config: OnRequest before it is sent,
+ * OnResponse once there is a response and OnFail when there is none
+ */
+func (c *HttpClient) RequestWithContext(ctx context.Context, request HttpRequest, httpResponse RawResponseToHttpResponseTransformer) (HttpResponse, error) {
+	done, err := c.Track()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	if ctx == nil {
+		ctx = request.GetContext()
+	}
+
+	interceptors := c.config.GetInterceptors()
+	for _, interceptor := range interceptors {
+		interceptRequest(ctx, interceptor, request, *c.config)
+	}
+	summary := request.ToSummary()
+	response, err := c.execute(ctx, request, summary, httpResponse)
+	if response == nil && err != nil {
+		return c.fail(ctx, interceptors, err, summary)
+	}
+	for _, interceptor := range interceptors {
+		interceptResponse(ctx, interceptor, response, summary, *c.config)
+	}
+	return response, err
+}
+
+/**
+ * Hand a failure without a response to OnFail of the interceptors in order. The first one returning
+ * a response recovers the request, an error returned instead is passed on to the next ones
+ * @return the response of the interceptor which recovered, or the last error
+ */
+func (c *HttpClient) fail(ctx context.Context, interceptors []Interceptor, err error, summary HttpRequestSummary) (HttpResponse, error) {
+	for _, interceptor := range interceptors {
+		response, failure := interceptFail(ctx, interceptor, err, summary, *c.config)
+		if response != nil && failure == nil {
+			return response, nil
+		}
+		if failure != nil {
+			err = failure
+		}
+	}
+	return nil, err
+}
+
+/**
+ * Send the request with the default headers of the config, retrying as shouldRetry allows.
+ * The responses of attempts which are retried are drained and closed first
+ */
+func (c *HttpClient) execute(ctx context.Context, request HttpRequest, summary HttpRequestSummary, httpResponse RawResponseToHttpResponseTransformer) (HttpResponse, error) {
+	headers, err := mergeDefaultHeaders(c.config.DefaultHeaders, request.GetHeaders())
+	if err != nil {
+		return nil, err
+	}
+	decompress, ok := request.GetDecompressResponse()
+	if !ok {
+		decompress = c.config.ResponseDecompressionOn
+	}
+	negotiateAcceptEncoding(&headers, decompress)
+	var content []byte
+	if body := request.getBody(); body != nil {
+		if content, err = body.GetContent(); err != nil {
+			return nil, err
+		}
+		if contentType := body.GetContentType(); contentType != "" && !headers.ContainsKey(CONTENT_TYPE) {
+			headers.Add(CONTENT_TYPE, contentType)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		raw, response, err := c.exchange(ctx, request, summary, content, headers, decompress, httpResponse)
+		if err == nil || !shouldRetry(c.config, request.getHttpMethod(), err, attempt) {
+			return response, err
+		}
+		discardResponse(raw)
+		if err := waitForRetry(ctx, attempt); err != nil {
+			return nil, classifyTransportError(ctx, err, summary, c.config.GetTimeouts().Merge(request.GetTimeouts()))
+		}
+	}
+}
+
+/**
+ * Send one attempt, with the body compressed by sendCompressed, and check the status of its response
+ * @return the raw response, the response and the error of sending or of checkStatus
+ */
+func (c *HttpClient) exchange(ctx context.Context, request HttpRequest, summary HttpRequestSummary, content []byte, headers Headers, decompress bool, httpResponse RawResponseToHttpResponseTransformer) (RawResponse, HttpResponse, error) {
+	compress, ok := request.GetCompressRequest()
+	if !ok {
+		compress = c.config.RequestCompressionOn
+	}
+	raw, err := sendCompressed(c.config, compress, content, headers, func(content []byte, headers Headers) (RawResponse, error) {
+		return c.send(ctx, request, summary, content, headers, decompress)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	response := httpResponse(raw)
+	return raw, response, checkStatus(c.config, request.GetExpectedStatus(), response, summary)
+}
+
+/**
+ * Send one attempt of a request
+ * @param ctx the context of the request
+ * @param request the request
+ * @param summary the summary of the request for errors and timeouts
+ * @param content the body, nil for none
+ * @param headers the headers to send
+ * @param decompress if the response body is decompressed
+ * @return the response, whose body stops the timer of the request once it is closed, or a classified error
+ */
+func (c *HttpClient) send(ctx context.Context, request HttpRequest, summary HttpRequestSummary, content []byte, headers Headers, decompress bool) (RawResponse, error) {
+	wire, err := wireHeaders(c.config, headers, request.GetAllowedHopByHop()...)
+	if err != nil {
+		return nil, err
+	}
+	timeouts := c.config.GetTimeouts().Merge(request.GetTimeouts())
+	timer, timed := startRequestTimer(ctx, timeouts, summary)
+	timed = withProxy(c.config.GetConnectionPool().WithTrace(timed), request.GetProxy())
+
+	var body io.Reader
+	if content != nil {
+		body = bytes.NewReader(content)
+	}
+	outgoing, err := http.NewRequestWithContext(timed, string(request.getHttpMethod()), request.GetUrl(), body)
+	if err != nil {
+		timer.finish()
+		return nil, err
+	}
+	outgoing.Header = wire
+	if host := wire.Get(HOST); host != "" {
+		outgoing.Host = host
+	}
+
+	started := time.Now()
+	response, err := c.client.Do(outgoing)
+	if err != nil {
+		err = classifyTransportError(timed, timer.err(err), summary, timeouts)
+		timer.finish()
+		return nil, err
+	}
+	response.Body = timer.wrapBody(response.Body)
+	return NewHttpRawResponse(response, c.config, time.Since(started)).WithDecompression(decompress), nil
+}
+
+/**
+ * Start a GET request
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Get(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodGet, url)
+}
+
+/**
+ * Start a HEAD request
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Head(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodHead, url)
+}
+
+/**
+ * Start a POST request, set its body with WithBody
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Post(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodPost, url)
+}
+
+/**
+ * Start a PUT request, set its body with WithBody
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Put(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodPut, url)
+}
+
+/**
+ * Start a PATCH request, set its body with WithBody
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Patch(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodPatch, url)
+}
+
+/**
+ * Start a DELETE request
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Delete(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodDelete, url)
+}
+
+/**
+ * Start an OPTIONS request
+ * @param url the url, may hold {name} route params and be relative to Config.DefaultBaseUrl
+ * @return the request
+ */
+func (c *HttpClient) Options(url string) *BaseRequest {
+	return NewBaseRequest(c.config, c, HttpMethodOptions, url)
+}
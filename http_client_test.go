@@ -0,0 +1,497 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// a request with only what the send path reads
+type clientRequest struct {
+	HttpRequest
+	method     HttpMethod
+	url        string
+	headers    Headers
+	body       Body
+	expected   []int
+	timeouts   Timeouts
+	compress   *bool
+	decompress *bool
+}
+
+func newClientRequest(method HttpMethod, url string) *clientRequest {
+	var request = new(clientRequest)
+	request.method = method
+	request.url = url
+	request.headers = *NewHeaders()
+	return request
+}
+
+func (r *clientRequest) getHttpMethod() HttpMethod { return r.method }
+func (r *clientRequest) GetUrl() string            { return r.url }
+func (r *clientRequest) GetHeaders() Headers       { return r.headers }
+func (r *clientRequest) getBody() Body             { return r.body }
+func (r *clientRequest) GetTimeouts() Timeouts     { return r.timeouts }
+func (r *clientRequest) GetCompressRequest() (bool, bool) {
+	if r.compress == nil {
+		return false, false
+	}
+	return *r.compress, true
+}
+func (r *clientRequest) GetDecompressResponse() (bool, bool) {
+	if r.decompress == nil {
+		return false, false
+	}
+	return *r.decompress, true
+}
+func (r *clientRequest) GetExpectedStatus() []int      { return r.expected }
+func (r *clientRequest) GetAllowedHopByHop() []string  { return nil }
+func (r *clientRequest) GetProxy() Proxy               { return Proxy{} }
+func (r *clientRequest) GetContext() context.Context   { return context.Background() }
+func (r *clientRequest) GetCreationTime() time.Time    { return time.Time{} }
+func (r *clientRequest) ToSummary() HttpRequestSummary { return NewRequestSummary(r, r.url, 0) }
+
+// a response holding the body as a string
+type clientResponse struct {
+	HttpResponse
+	status  int
+	headers Headers
+	body    string
+}
+
+func (r *clientResponse) GetStatus() int        { return r.status }
+func (r *clientResponse) GetStatusText() string { return "" }
+func (r *clientResponse) GetHeaders() Headers   { return r.headers }
+func (r *clientResponse) GetBody() interface{}  { return r.body }
+func (r *clientResponse) IsSuccess() bool       { return r.status/100 == 2 }
+
+func asClientResponse(raw RawResponse) HttpResponse {
+	body := raw.GetContentAsString()
+	raw.GetContent().Close()
+	return &clientResponse{status: raw.GetStatus(), headers: raw.GetHeaders(), body: body}
+}
+
+func newTestClient(t *testing.T, config *Config) *HttpClient {
+	client, err := NewHttpClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestHttpClientSendsRequestAndDecodesResponse(t *testing.T) {
+	var received http.Header
+	var receivedBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		receivedBody, _ = io.ReadAll(r.Body)
+		w.Header().Set(CONTENT_ENCODING, ENCODING_GZIP)
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte("created"))
+		gz.Close()
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, NewDefaultConfig())
+	request := newClientRequest(HttpMethodPost, server.URL)
+	request.headers.Add("user-agent", "custom")
+	request.body = NewBytesBody([]byte(`{"a":1}`), string(APPLICATION_JSON))
+
+	response, err := client.RequestWithContext(context.Background(), request, asClientResponse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := response.GetBody(); body != "created" {
+		t.Errorf("body not decompressed: %q", body)
+	}
+	if got := received.Values(USER_AGENT); len(got) != 1 || got[0] != "custom" {
+		t.Errorf("User-Agent sent as %v", got)
+	}
+	if received.Get(ACCEPT_ENCODING) != DEFAULT_ACCEPT_ENCODING || received.Get(CONTENT_TYPE) != string(APPLICATION_JSON) {
+		t.Errorf("headers sent: %v", received)
+	}
+	if string(receivedBody) != `{"a":1}` {
+		t.Errorf("body sent: %q", receivedBody)
+	}
+}
+
+func TestHttpClientChecksTheStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no such user"))
+	}))
+	defer server.Close()
+
+	var config = NewDefaultConfig()
+	client := newTestClient(t, config)
+	request := newClientRequest(HttpMethodGet, server.URL)
+	if _, err := client.RequestWithContext(context.Background(), request, asClientResponse); err != nil {
+		t.Errorf("a 404 failed without ErrorOnFailureStatus: %v", err)
+	}
+
+	request.expected = []int{http.StatusOK}
+	response, err := client.RequestWithContext(context.Background(), request, asClientResponse)
+	var status *StatusError
+	if !errors.As(err, &status) || status.Status != NOT_FOUND || status.Body != "no such user" || status.Response != response {
+		t.Errorf("got %v", err)
+	}
+
+	config.ErrorOnFailureStatus = true
+	request.expected = nil
+	if _, err := client.RequestWithContext(context.Background(), request, asClientResponse); !errors.As(err, &status) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestHttpClientEnforcesTimeouts(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := newTestClient(t, NewDefaultConfig())
+	request := newClientRequest(HttpMethodGet, server.URL)
+	request.timeouts.ResponseHeader = 50 * time.Millisecond
+	_, err := client.RequestWithContext(context.Background(), request, asClientResponse)
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Phase != TimeoutPhaseResponseHeader {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestHttpClientRefusesRequestsAfterClose(t *testing.T) {
+	var client Client = newTestClient(t, NewDefaultConfig())
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.RequestWithContext(context.Background(), newClientRequest(HttpMethodGet, "http://localhost/"), asClientResponse); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestHttpClientRetries(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			// close the connection without a response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		// a fresh connection for every request, net/http retries some requests on reused ones by itself
+		w.Header().Set(CONNECTION, "close")
+	}))
+	defer server.Close()
+
+	var config = NewDefaultConfig()
+	config.AutomaticRetries = true
+	client := newTestClient(t, config)
+	response, err := client.RequestWithContext(context.Background(), newClientRequest(HttpMethodGet, server.URL), asClientResponse)
+	if err != nil || response.GetStatus() != http.StatusOK || attempts.Load() != 3 {
+		t.Errorf("got %v after %d attempts", err, attempts.Load())
+	}
+
+	attempts.Store(0)
+	config.MaxRetries = 0
+	if _, err := client.RequestWithContext(context.Background(), newClientRequest(HttpMethodGet, server.URL), asClientResponse); err == nil || attempts.Load() != 1 {
+		t.Errorf("got %v after %d attempts", err, attempts.Load())
+	}
+
+	attempts.Store(0)
+	config.MaxRetries = DEFAULT_MAX_RETRIES
+	if _, err := client.RequestWithContext(context.Background(), newClientRequest(HttpMethodPost, server.URL), asClientResponse); err == nil || attempts.Load() != 1 {
+		t.Errorf("a POST which reached the server was sent %d times", attempts.Load())
+	}
+}
+
+func TestHttpClientCompressesLargeBodies(t *testing.T) {
+	var encodings []string
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get(CONTENT_ENCODING))
+		var body io.Reader = r.Body
+		if r.Header.Get(CONTENT_ENCODING) == ENCODING_GZIP {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			body = gz
+		}
+		content, _ := io.ReadAll(body)
+		bodies = append(bodies, string(content))
+	}))
+	defer server.Close()
+
+	var config = NewDefaultConfig()
+	config.RequestCompressionOn = true
+	config.RequestCompressionThreshold = 10
+	client := newTestClient(t, config)
+	send := func(body string, compress *bool) {
+		encodings, bodies = nil, nil
+		request := newClientRequest(HttpMethodPost, server.URL)
+		request.body = NewBytesBody([]byte(body), "text/plain")
+		request.compress = compress
+		if _, err := client.RequestWithContext(context.Background(), request, asClientResponse); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	large := strings.Repeat("compress me ", 10)
+	send(large, nil)
+	if len(encodings) != 1 || encodings[0] != ENCODING_GZIP || bodies[0] != large {
+		t.Errorf("large body sent as %v %q", encodings, bodies)
+	}
+	send("small", nil)
+	if encodings[0] != "" || bodies[0] != "small" {
+		t.Errorf("small body sent as %v", encodings)
+	}
+	off := false
+	send(large, &off)
+	if encodings[0] != "" {
+		t.Errorf("CompressRequest(false) ignored: %v", encodings)
+	}
+}
+
+func TestHttpClientKeepsBodiesAsSentWithoutDecompression(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write([]byte("hello"))
+	gz.Close()
+	var acceptEncoding []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Values(ACCEPT_ENCODING)
+		w.Header().Set(CONTENT_ENCODING, ENCODING_GZIP)
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	client := newTestClient(t, NewDefaultConfig())
+	request := newClientRequest(HttpMethodGet, server.URL)
+	off := false
+	request.decompress = &off
+	var body []byte
+	_, err := client.RequestWithContext(context.Background(), request, func(raw RawResponse) HttpResponse {
+		body = raw.GetContentAsBytes()
+		raw.GetContent().Close()
+		return &clientResponse{status: raw.GetStatus()}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(acceptEncoding) != 0 {
+		t.Errorf("Accept-Encoding %v sent with DecompressResponse(false)", acceptEncoding)
+	}
+	if !bytes.Equal(body, compressed.Bytes()) {
+		t.Errorf("body was decoded: %q", body)
+	}
+}
+
+func TestHttpClientSendsDefaultHeaders(t *testing.T) {
+	var received []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Clone())
+		if len(received) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var config = NewDefaultConfig()
+	config.AutomaticRetries = true
+	config.ErrorOnFailureStatus = true
+	tokens := 0
+	config.DefaultHeaders.AddSupplier(AUTHORIZATION, func() (string, error) {
+		tokens++
+		return "Bearer token", nil
+	})
+	client := newTestClient(t, config)
+
+	if _, err := client.RequestWithContext(context.Background(), newClientRequest(HttpMethodGet, server.URL), asClientResponse); err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 1 || len(received) != 2 {
+		t.Errorf("supplier called %d times for %d attempts", tokens, len(received))
+	}
+	for _, headers := range received {
+		if headers.Get(USER_AGENT) != DEFAULT_USER_AGENT || headers.Get(AUTHORIZATION) != "Bearer token" {
+			t.Errorf("default headers missing: %v", headers)
+		}
+	}
+
+	received = nil
+	request := newClientRequest(HttpMethodGet, server.URL)
+	request.headers.Add(USER_AGENT, "custom")
+	client.RequestWithContext(context.Background(), request, asClientResponse)
+	if got := received[0].Values(USER_AGENT); len(got) != 1 || got[0] != "custom" {
+		t.Errorf("User-Agent sent as %v", got)
+	}
+
+	received = nil
+	failing := errors.New("token service down")
+	config.DefaultHeaders.ReplaceSupplier(AUTHORIZATION, func() (string, error) { return "", failing })
+	if _, err := client.RequestWithContext(context.Background(), newClientRequest(HttpMethodGet, server.URL), asClientResponse); !errors.Is(err, failing) || len(received) != 0 {
+		t.Errorf("got %v after %d requests", err, len(received))
+	}
+}
+
+type contextKey string
+
+// records what the client hands to the interceptor and recovers failures with a response of status 0
+type recordingInterceptor struct {
+	requests  []string
+	responses []int
+	failures  []error
+	contexts  []interface{}
+	recover   bool
+}
+
+func (i *recordingInterceptor) OnRequest(request HttpRequest, config Config) {
+	i.OnRequestContext(context.Background(), request, config)
+}
+
+func (i *recordingInterceptor) OnResponse(response HttpResponse, request HttpRequestSummary, config Config) {
+	i.OnResponseContext(context.Background(), response, request, config)
+}
+
+func (i *recordingInterceptor) OnFail(e error, request HttpRequestSummary, config Config) (HttpResponse, error) {
+	return i.OnFailContext(context.Background(), e, request, config)
+}
+
+func (i *recordingInterceptor) OnRequestContext(ctx context.Context, request HttpRequest, config Config) {
+	i.requests = append(i.requests, request.GetUrl())
+	i.contexts = append(i.contexts, ctx.Value(contextKey("trace")))
+	request.HeaderReplace("X-Trace", "traced")
+}
+
+func (i *recordingInterceptor) OnResponseContext(ctx context.Context, response HttpResponse, request HttpRequestSummary, config Config) {
+	i.responses = append(i.responses, response.GetStatus())
+}
+
+func (i *recordingInterceptor) OnFailContext(ctx context.Context, e error, request HttpRequestSummary, config Config) (HttpResponse, error) {
+	i.failures = append(i.failures, e)
+	if i.recover {
+		return newFailedResponse(&config, nil), nil
+	}
+	return nil, e
+}
+
+func TestHttpClientCallsTheInterceptors(t *testing.T) {
+	var traced string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traced = r.Header.Get("X-Trace")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	var interceptor = new(recordingInterceptor)
+	config := NewDefaultConfig()
+	config.AddInterceptor(interceptor)
+	client := newTestClient(t, config)
+
+	ctx := context.WithValue(context.Background(), contextKey("trace"), "abc")
+	response := client.Get(server.URL).WithContext(ctx).AsString()
+	if response.GetStatus() != http.StatusAccepted || traced != "traced" {
+		t.Errorf("status %d, header set by OnRequest %q", response.GetStatus(), traced)
+	}
+	if len(interceptor.requests) != 1 || interceptor.requests[0] != server.URL || interceptor.contexts[0] != "abc" {
+		t.Errorf("OnRequest saw %v with %v", interceptor.requests, interceptor.contexts)
+	}
+	if len(interceptor.responses) != 1 || interceptor.responses[0] != http.StatusAccepted || len(interceptor.failures) != 0 {
+		t.Errorf("OnResponse saw %v, OnFail %v", interceptor.responses, interceptor.failures)
+	}
+}
+
+func TestHttpClientLetsInterceptorsRecoverFailures(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+	var interceptor = new(recordingInterceptor)
+	config := NewDefaultConfig()
+	config.AddInterceptor(interceptor)
+	client := newTestClient(t, config)
+
+	_, err := client.Request(client.Get(url), asClientResponse)
+	var connect *ConnectError
+	if !errors.As(err, &connect) || len(interceptor.failures) != 1 || interceptor.failures[0] != err {
+		t.Fatalf("got %v, OnFail saw %v", err, interceptor.failures)
+	}
+
+	interceptor.recover = true
+	response, err := client.Request(client.Get(url), asClientResponse)
+	if err != nil || response == nil || response.GetStatus() != 0 {
+		t.Errorf("not recovered: %v", err)
+	}
+	if len(interceptor.responses) != 0 {
+		t.Errorf("OnResponse called for a failure: %v", interceptor.responses)
+	}
+}
+
+// counts the response bodies handed out and closed
+type closeTrackingTransport struct {
+	http.RoundTripper
+	opened int
+	closed int
+}
+
+type trackedBody struct {
+	io.ReadCloser
+	transport *closeTrackingTransport
+}
+
+func (b *trackedBody) Close() error {
+	b.transport.closed++
+	return b.ReadCloser.Close()
+}
+
+func (t *closeTrackingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := t.RoundTripper.RoundTrip(request)
+	if err == nil {
+		t.opened++
+		response.Body = &trackedBody{ReadCloser: response.Body, transport: t}
+	}
+	return response, err
+}
+
+func TestHttpClientClosesTheResponsesOfRetriedAttempts(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "busy")
+		}
+	}))
+	defer server.Close()
+	var config = NewDefaultConfig()
+	config.ErrorOnFailureStatus = true
+	config.AutomaticRetries = true
+	transport, err := newTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tracking = &closeTrackingTransport{RoundTripper: transport}
+	client := NewHttpClientWithTransport(config, tracking)
+	defer client.Close()
+
+	// a transformer which leaves the body to the caller
+	response, err := client.Request(newClientRequest(HttpMethodGet, server.URL), func(raw RawResponse) HttpResponse {
+		return &clientResponse{status: raw.GetStatus(), headers: raw.GetHeaders()}
+	})
+	if err != nil || response.GetStatus() != http.StatusOK {
+		t.Fatal(err)
+	}
+	if tracking.opened != 3 || tracking.closed != 2 {
+		t.Errorf("%d of %d bodies closed, want the 2 retried ones", tracking.closed, tracking.opened)
+	}
+}
@@ -1,6 +1,10 @@
 package main
 
-import "time"
+import (
+	"context"
+	"iter"
+	"time"
+)
 
 type HttpRequest interface {
 
@@ -44,6 +48,14 @@ type HttpRequest interface {
 	 */
 	Accept(value string) HttpRequest
 
+	/**
+	 * Set an Accept, Accept-Language or Accept-Charset header with q values, replacing the one set before
+	 * <pre>request.WithAccept(NewAcceptHeader().Add("application/vnd.acme.v2+json", 1).Add("application/json", 0.5))</pre>
+	 * @param accept the header, its name is taken from the builder
+	 * @return this request builder
+	 */
+	WithAccept(accept *AcceptHeader) HttpRequest
+
 	/**
 	 * The encoding to expect the response to be for cases where the server fails to respond with the proper encoding
 	 * @param encoding a valid mime type for the Accept header
@@ -51,8 +63,39 @@ type HttpRequest interface {
 	 */
 	ResponseEncoding(encoding string) HttpRequest
 
+	/**
+	 * Turn compression of the request body on or off for this request, overriding Config.RequestCompressionOn.
+	 * Bodies smaller than Config.RequestCompressionThreshold are never compressed
+	 * @param enabled if the request body should be compressed
+	 * @return this request builder
+	 */
+	CompressRequest(enabled bool) HttpRequest
+
+	/**
+	 * Turn decoding of gzip, deflate, br and zstd response bodies on or off for this request.
+	 * When on, the Accept-Encoding header is sent unless one was set. When off the body
+	 * is returned exactly as the server sent it. Defaults to Config.ResponseDecompressionOn.
+	 * A custom http.Transport must have DisableCompression set, otherwise net/http asks for gzip
+	 * and decodes it by itself whatever this is set to
+	 * @param enabled if the response body should be decompressed
+	 * @return this request builder
+	 */
+	DecompressResponse(enabled bool) HttpRequest
+
+	/**
+	 * Treat every status except the given ones as a failure: the response is turned into a StatusError
+	 * which is returned by Client.RequestWithContext and the async methods, and by GetParsingError of the response.
+	 * Overrides Config.ErrorOnFailureStatus for this request
+	 * @param codes the expected status codes
+	 * @return this request builder
+	 */
+	ExpectStatus(codes ...int) HttpRequest
+
 	/**
 	 * Add a http header, HTTP supports multiple of the same header. This will continue to append new values
+	 * Replaces the value of Config.DefaultHeaders for the name, if any.
+	 * The name keeps its case. Names and values are checked with ValidateHeaders when the request is sent,
+	 * a bad header, for example one with a line break in its value, fails the request with a HeaderError
 	 * @param name name of the header
 	 * @param value value for the header
 	 * @return this request builder
@@ -67,6 +110,13 @@ type HttpRequest interface {
 	 */
 	HeaderReplace(name string, value string) HttpRequest
 
+	/**
+	 * Allow setting hop-by-hop headers like Connection or Upgrade on this request, which are refused otherwise
+	 * @param names the hop-by-hop headers to allow
+	 * @return this request builder
+	 */
+	AllowHopByHop(names ...string) HttpRequest
+
 	/**
 	 * Add headers as a map
 	 * @param headerMap a map of headers
@@ -130,19 +180,57 @@ type HttpRequest interface {
 	WithObjectMapper(mapper ObjectMapper) HttpRequest
 
 	/**
-	 * Set a socket timeout for this request
+	 * Set a socket timeout for this request, the same as IdleReadTimeout
 	 * @param millies the time in millies
 	 * @return this request builder
 	 */
 	SocketTimeout(millies int) HttpRequest
 
 	/**
-	 * Set a connect timeout for this request
+	 * Set a connect timeout for this request, the same as DialTimeout
 	 * @param millies the time in millies
 	 * @return this request builder
 	 */
 	ConnectTimeout(millies int) HttpRequest
 
+	/**
+	 * Set the time allowed to establish the TCP connection. Exceeding it fails with a TimeoutError of TimeoutPhaseDial
+	 * @param timeout the timeout, 0 uses the config value
+	 * @return this request builder
+	 */
+	DialTimeout(timeout time.Duration) HttpRequest
+
+	/**
+	 * Set the time allowed for the TLS handshake. Exceeding it fails with a TimeoutError of TimeoutPhaseTLSHandshake
+	 * @param timeout the timeout, 0 uses the config value
+	 * @return this request builder
+	 */
+	TLSHandshakeTimeout(timeout time.Duration) HttpRequest
+
+	/**
+	 * Set the time allowed between sending the request and the first byte of the response.
+	 * Exceeding it fails with a TimeoutError of TimeoutPhaseResponseHeader
+	 * @param timeout the timeout, 0 uses the config value
+	 * @return this request builder
+	 */
+	ResponseHeaderTimeout(timeout time.Duration) HttpRequest
+
+	/**
+	 * Set the time allowed between two reads of the response body.
+	 * Exceeding it fails with a TimeoutError of TimeoutPhaseIdleRead
+	 * @param timeout the timeout, 0 uses the config value
+	 * @return this request builder
+	 */
+	IdleReadTimeout(timeout time.Duration) HttpRequest
+
+	/**
+	 * Set the time allowed for the whole request including reading the body.
+	 * Exceeding it fails with a TimeoutError of TimeoutPhaseTotal
+	 * @param timeout the timeout, 0 uses the config value
+	 * @return this request builder
+	 */
+	TotalTimeout(timeout time.Duration) HttpRequest
+
 	/**
 	 * Set a proxy for this request. Only basic proxies are supported.
 	 * @param host the host url
@@ -151,6 +239,14 @@ type HttpRequest interface {
 	 */
 	Proxy(host string, port int) HttpRequest
 
+	/**
+	 * Set the context for this request. Cancelling the context or reaching its deadline
+	 * aborts the request with a CancelledError. Interceptors implementing ContextInterceptor receive it.
+	 * @param ctx the context, context.Background() is used when this is never called
+	 * @return this request builder
+	 */
+	WithContext(ctx context.Context) HttpRequest
+
 	/**
 	 * sets a download monitor for monitoring the response. this could be used for drawing a progress bar
 	 * @param monitor a ProgressMonitor
@@ -177,16 +273,25 @@ type HttpRequest interface {
 	AsJson() JsonHttpResponse
 
 	/**
-	 * Executes the request and returns the response with the body mapped into T by a configured ObjectMapper
+	 * Executes the request and returns the response with the body mapped into T by a configured ObjectMapper.
+	 * Unless the request has its own ObjectMapper the mapper is chosen by the Content-Type of the response from
+	 * Config.Mappers, which also provides the Accept header when none was set
 	 * @param responseClass the class to return. This will be passed to the ObjectMapper
 	 * @param <T> the return type
 	 * @return a response
 	 */
 	AsObject() HttpResponse
 
+	/**
+	 * Executes the request and decodes the body into target, like AsObject
+	 * @param target a pointer to the value to decode into, it becomes the body of the response
+	 * @return a response
+	 */
+	AsObjectInto(target interface{}) ObjectHttpResponse
+
 	/**
 	 * Execute the request and pass the raw response to a function for mapping.
-	 * This raw response contains the original InputStream and is suitable for
+	 * This raw response contains the live body as an io.ReadCloser and is suitable for
 	 * reading large responses.
 	 * @param function the function to map the response into a object of T
 	 * @param <T> The type of the response mapping
@@ -206,13 +311,42 @@ type HttpRequest interface {
 	 * Allows for following paging links common in many APIs.
 	 * Each request will result in the same request (headers, etc) but will use the "next" link provided by the extract function.
 	 *
-	 * @param <T> the type of response.
+	 * Paging stops after DEFAULT_MAX_PAGES pages.
+	 *
+	 * @param mappingFunction a function to return the desired return type leveraging one of the as* methods (asString, asObject, etc).
+	 * @param linkExtractor a function to extract a "next" link to follow. Retuning an empty string ends the paging
+	 * @return a PagedList of the responses
+	 */
+	AsPaged(mappingFunction PageMapper, linkExtractor LinkExtractor) PagedList
+
+	/**
+	 * Same as AsPaged but with an explicit guard against APIs which never stop returning "next" links
+	 * @param mappingFunction a function to return the desired return type leveraging one of the as* methods (asString, asObject, etc).
+	 * @param linkExtractor a function to extract a "next" link to follow. Retuning an empty string ends the paging
+	 * @param maxPages the maximum number of pages to request, a value <= 0 means no limit
+	 * @return a PagedList of the responses
+	 */
+	AsPagedWithMaxPages(mappingFunction PageMapper, linkExtractor LinkExtractor, maxPages int) PagedList
+
+	/**
+	 * Iterate lazily over the items of every page, for example
+	 * <pre>for item, err := range request.Pages(mapper, NextLinkFromHeader) { ... }</pre>
+	 * Only one page is held in memory at a time, the next one is requested when the items of the current page are used up.
+	 * A page body which is a slice or array yields its elements. A failed page yields a PageError and ends the iteration.
+	 * @param mappingFunction a function to return the desired return type leveraging one of the as* methods (asString, asObject, etc).
+	 * @param linkExtractor a function to extract a "next" link to follow. Retuning an empty string ends the paging
+	 * @return an iterator over the items
+	 */
+	Pages(mappingFunction PageMapper, linkExtractor LinkExtractor) iter.Seq2[interface{}, error]
+
+	/**
+	 * Same as Pages with a page limit and optional prefetching of the next page
 	 * @param mappingFunction a function to return the desired return type leveraging one of the as* methods (asString, asObject, etc).
-	 * @param linkExtractor a function to extract a "next" link to follow. Retuning a null or empty string ends the paging
-	 * @return a PagedList of your type
+	 * @param linkExtractor a function to extract a "next" link to follow. Retuning an empty string ends the paging
+	 * @param options the paging options
+	 * @return an iterator over the items
 	 */
-	//  <T> PagedList<T> asPaged(Function<HttpRequest, HttpResponse> mappingFunction,
-	//   Function<HttpResponse<T>, String> linkExtractor);
+	PagesWithOptions(mappingFunction PageMapper, linkExtractor LinkExtractor, options PageOptions) iter.Seq2[interface{}, error]
 
 	/**
 	 * Executes the request and returns the response without parsing the body
@@ -220,21 +354,110 @@ type HttpRequest interface {
 	 */
 	AsEmpty() HttpResponse
 
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped into a String
+	 * @param ctx cancels the request while it is queued or running
+	 * @return a Future for the response
+	 */
+	AsStringAsync(ctx context.Context) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped into a String
+	 * @param ctx cancels the request while it is queued or running
+	 * @param callback notified when the request completes, fails or is cancelled
+	 * @return a Future for the response
+	 */
+	AsStringAsyncWithCallback(ctx context.Context, callback Callback) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped into a byte[]
+	 * @param ctx cancels the request while it is queued or running
+	 * @return a Future for the response
+	 */
+	AsBytesAsync(ctx context.Context) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped into a byte[]
+	 * @param ctx cancels the request while it is queued or running
+	 * @param callback notified when the request completes, fails or is cancelled
+	 * @return a Future for the response
+	 */
+	AsBytesAsyncWithCallback(ctx context.Context, callback Callback) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped into a JsonNode
+	 * @param ctx cancels the request while it is queued or running
+	 * @return a Future for the response
+	 */
+	AsJsonAsync(ctx context.Context) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped into a JsonNode
+	 * @param ctx cancels the request while it is queued or running
+	 * @param callback notified when the request completes, fails or is cancelled
+	 * @return a Future for the response
+	 */
+	AsJsonAsyncWithCallback(ctx context.Context, callback Callback) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped by a configured ObjectMapper
+	 * @param ctx cancels the request while it is queued or running
+	 * @return a Future for the response
+	 */
+	AsObjectAsync(ctx context.Context) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response with the body mapped by a configured ObjectMapper
+	 * @param ctx cancels the request while it is queued or running
+	 * @param callback notified when the request completes, fails or is cancelled
+	 * @return a Future for the response
+	 */
+	AsObjectAsyncWithCallback(ctx context.Context, callback Callback) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and writes the contents into a file
+	 * @param ctx cancels the request while it is queued or running
+	 * @param path The path to the file.
+	 * @param copyOptions options specifying how the copy should be done
+	 * @return a Future for the response
+	 */
+	AsFileAsync(ctx context.Context, path string, copyOptions []CopyOption) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response without parsing the body
+	 * @param ctx cancels the request while it is queued or running
+	 * @return a Future for the response
+	 */
+	AsEmptyAsync(ctx context.Context) *Future
+
+	/**
+	 * Executes the request on the Config's async executor and returns the response without parsing the body
+	 * @param ctx cancels the request while it is queued or running
+	 * @param callback notified when the request completes, fails or is cancelled
+	 * @return a Future for the response
+	 */
+	AsEmptyAsyncWithCallback(ctx context.Context, callback Callback) *Future
+
 	/**
 	 * Execute the request and pass the raw response to a consumer.
-	 * This raw response contains the original InputStream and is suitable for
-	 * reading large responses
+	 * This raw response contains the live body as an io.ReadCloser and is suitable for
+	 * reading large responses.
+	 * The body is closed once the consumer returns.
 	 * @param consumer a consumer function
+	 * @return the error of the request or of the consumer
 	 */
-	//void thenConsume(Consumer<RawResponse> consumer);
+	ThenConsume(consumer RawResponseConsumer) error
 
 	/**
 	 * Execute the request asynchronously and pass the raw response to a consumer.
-	 * This raw response contains the original InputStream and is suitable for
-	 * reading large responses
+	 * This raw response contains the live body as an io.ReadCloser and is suitable for
+	 * reading large responses.
+	 * The body is closed once the consumer returns.
+	 * @param ctx cancels the request while it is queued or running
 	 * @param consumer a consumer function
+	 * @return a Future which completes once the consumer returned, holding no response
 	 */
-	//void thenConsumeAsync(Consumer<RawResponse> consumer);
+	ThenConsumeAsync(ctx context.Context, consumer RawResponseConsumer) *Future
 
 	/**
 	 * @return The HTTP method of the request
@@ -266,11 +489,41 @@ type HttpRequest interface {
 	 */
 	GetConnectTimeout() int
 
+	/**
+	 * @return the timeouts set on this request, zero values fall back to Config.GetTimeouts()
+	 */
+	GetTimeouts() Timeouts
+
+	/**
+	 * @return the value set with CompressRequest, false when it was never called
+	 */
+	GetCompressRequest() (bool, bool)
+
+	/**
+	 * @return the value set with DecompressResponse, false when it was never called
+	 */
+	GetDecompressResponse() (bool, bool)
+
+	/**
+	 * @return the status codes set with ExpectStatus, empty if none were
+	 */
+	GetExpectedStatus() []int
+
+	/**
+	 * @return the hop-by-hop headers allowed with AllowHopByHop
+	 */
+	GetAllowedHopByHop() []string
+
 	/**
 	 * @return the proxy for this request
 	 */
 	GetProxy() Proxy
 
+	/**
+	 * @return the context for this request, never nil
+	 */
+	GetContext() context.Context
+
 	/**
 	 * @return a summary for the response, used in metrics
 	 */
@@ -1,4 +1,104 @@
 package main
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 type HttpRequestSummary interface {
+
+	/**
+	 * @return The HTTP method of the request
+	 */
+	GetHttpMethod() HttpMethod
+
+	/**
+	 * @return the url template before any route params were expanded (e.g. https://localhost/users/{id})
+	 */
+	GetRawPath() string
+
+	/**
+	 * @return the expanded url including the query string
+	 */
+	GetUrl() string
+
+	/**
+	 * @return the names of the headers sent with the request. Values are left out so the summary is safe to log
+	 */
+	GetHeaderNames() []string
+
+	/**
+	 * @return the size of the request body in bytes, or 0 if there was no body
+	 */
+	GetBodySize() int64
+
+	/**
+	 * @return the instant the request object was created in UTC (not when it was sent).
+	 */
+	GetCreationTime() time.Time
+
+	/**
+	 * @return a single line description of the request suitable for log lines
+	 */
+	String() string
+}
+
+type RequestSummary struct {
+	Method       HttpMethod
+	RawPath      string
+	Url          string
+	HeaderNames  []string
+	BodySize     int64
+	CreationTime time.Time
+}
+
+/**
+ * Build a summary from a request.
+ * @param request the request to summarize
+ * @param rawPath the url template before route params were expanded
+ * @param bodySize the size of the request body in bytes
+ * @return the summary
+ */
+func NewRequestSummary(request HttpRequest, rawPath string, bodySize int64) *RequestSummary {
+	var summary = new(RequestSummary)
+	headers := request.GetHeaders()
+	summary.Method = request.getHttpMethod()
+	summary.RawPath = rawPath
+	summary.Url = request.GetUrl()
+	summary.HeaderNames = headers.Names()
+	summary.BodySize = bodySize
+	summary.CreationTime = request.GetCreationTime()
+	return summary
+}
+
+func (s *RequestSummary) GetHttpMethod() HttpMethod {
+	return s.Method
+}
+
+func (s *RequestSummary) GetRawPath() string {
+	return s.RawPath
+}
+
+func (s *RequestSummary) GetUrl() string {
+	return s.Url
+}
+
+func (s *RequestSummary) GetHeaderNames() []string {
+	return s.HeaderNames
+}
+
+func (s *RequestSummary) GetBodySize() int64 {
+	return s.BodySize
+}
+
+func (s *RequestSummary) GetCreationTime() time.Time {
+	return s.CreationTime
+}
+
+/**
+ * @return the summary like this: <pre>POST https://localhost/users/fred headers=[Accept,Content-Type] body=42B</pre>
+ */
+func (s *RequestSummary) String() string {
+	return fmt.Sprintf("%s %s headers=[%s] body=%dB", s.Method, s.Url, strings.Join(s.HeaderNames, ","), s.BodySize)
 }
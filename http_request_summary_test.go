@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRequestSummary(t *testing.T) {
+	request := newClientRequest(HttpMethodPost, "https://localhost/users/fred?active=true")
+	request.headers.Add(AUTHORIZATION, "Bearer secret")
+	request.headers.Add(ACCEPT, "application/json")
+	request.headers.Add("authorization", "Basic other")
+	summary := NewRequestSummary(request, "https://localhost/users/{id}", 42)
+
+	if summary.GetHttpMethod() != HttpMethodPost || summary.GetRawPath() != "https://localhost/users/{id}" || summary.GetUrl() != request.url {
+		t.Errorf("got %+v", summary)
+	}
+	if names := summary.GetHeaderNames(); len(names) != 2 || names[0] != AUTHORIZATION || names[1] != ACCEPT {
+		t.Errorf("header names %v", names)
+	}
+	if line := summary.String(); line != "POST https://localhost/users/fred?active=true headers=[Authorization,Accept] body=42B" {
+		t.Errorf("String() = %q", line)
+	}
+	if strings.Contains(summary.String(), "secret") {
+		t.Error("a header value was logged")
+	}
+}
@@ -34,6 +34,7 @@ type HttpResponse interface {
 
 	/**
 	 * If the transformation to the body failed by an exception it will be kept here
+	 * When the status was not expected, see HttpRequest.ExpectStatus and Config.ErrorOnFailureStatus, this is the StatusError
 	 * @return a possible RuntimeException. Checked exceptions are wrapped in a UnirestException
 	 */
 	GetParsingError() error
@@ -71,14 +72,13 @@ type HttpResponse interface {
 	IfFailure(consumer HttpResponseConsumer) HttpResponse
 
 	/**
-	 * If the response was NOT a 200-series response or a mapping exception happened. map the original body into a error type and invoke this consumer
-	 * can be chained with ifSuccess
-	 * @param <E> the type of error class to map the body
-	 * @param errorClass the class of the error type to map to
-	 * @param consumer a function to consume a HttpResponse
+	 * If the response was NOT a 200-series response or a mapping exception happened. map the original body into target and invoke this consumer
+	 * can be chained with ifSuccess. Works the same when a StatusError was raised for the response, reach it through StatusError.Response
+	 * @param target a pointer to the error struct the body is decoded into, see MapError
+	 * @param consumer a function to consume the response and the error of decoding the body
 	 * @return the same response
 	 */
-	IfFailureWithError(err error, consumer HttpResponseConsumer) HttpResponse
+	IfFailureWithError(target interface{}, consumer HttpResponseErrorConsumer) HttpResponse
 
 	/**
 	 * @return true if the response was a 200-series response and no mapping exception happened, else false
@@ -86,13 +86,14 @@ type HttpResponse interface {
 	IsSuccess() bool
 
 	/**
-	 * Map the body into a error class if the response was NOT a 200-series response or a mapping exception happened.
-	 * Uses the system Object Mapper
-	 * @param <E> the response type
-	 * @param errorClass the class for the error
-	 * @return the error object
+	 * Map the original body into target if the response was NOT a 200-series response or a mapping exception happened.
+	 * Uses the ObjectMapper the config's Mappers have for the Content-Type of the body, else the one of the request or the config;
+	 * pass a *ProblemDetails for application/problem+json and application/problem+xml bodies
+	 * @param target a pointer to the error struct
+	 * @return nil when the response was a success or the body was decoded, ErrEmptyErrorBody when there was no body,
+	 * or a BodyParseError with the original body when it could not be decoded
 	 */
-	MapError(e error) error
+	MapError(target interface{}) error
 
 	/**
 	 * return a cookie collection parse from the set-cookie header
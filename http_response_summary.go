@@ -1,6 +1,113 @@
 package main
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type HttpResponseSummary interface {
 	GetStatus() int
 	GetStatusText() string
+
+	/**
+	 * @return the response headers
+	 */
+	GetHeaders() Headers
+
+	/**
+	 * @return the value of the Content-Length header, or -1 if it was missing or invalid
+	 */
+	GetContentLength() int64
+
+	/**
+	 * @return the value of the Content-Type header
+	 */
+	GetContentType() string
+
+	/**
+	 * @return the time between sending the request and receiving the response
+	 */
+	GetElapsed() time.Duration
+
+	/**
+	 * @return a single line description of the response suitable for log lines
+	 */
+	String() string
+}
+
+type ResponseSummary struct {
+	Status        int
+	StatusText    string
+	Headers       Headers
+	ContentLength int64
+	ContentType   string
+	Elapsed       time.Duration
+}
+
+/**
+ * Build a summary from a raw response.
+ * @param raw the raw response
+ * @param elapsed the time between sending the request and receiving the response
+ * @return the summary
+ */
+func NewResponseSummary(raw RawResponse, elapsed time.Duration) *ResponseSummary {
+	var summary = new(ResponseSummary)
+	summary.Status = raw.GetStatus()
+	summary.StatusText = raw.GetStatusText()
+	summary.Headers = raw.GetHeaders()
+	summary.ContentLength = parseContentLength(summary.Headers.GetFirst(CONTENT_LENGTH))
+	summary.ContentType = raw.GetContentType()
+	summary.Elapsed = elapsed
+	return summary
+}
+
+func parseContentLength(value string) int64 {
+	length, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || length < 0 {
+		return -1
+	}
+	return length
+}
+
+func (s *ResponseSummary) GetStatus() int {
+	return s.Status
+}
+
+func (s *ResponseSummary) GetStatusText() string {
+	return s.StatusText
+}
+
+func (s *ResponseSummary) GetHeaders() Headers {
+	return s.Headers
+}
+
+func (s *ResponseSummary) GetContentLength() int64 {
+	return s.ContentLength
+}
+
+func (s *ResponseSummary) GetContentType() string {
+	return s.ContentType
+}
+
+func (s *ResponseSummary) GetElapsed() time.Duration {
+	return s.Elapsed
+}
+
+/**
+ * @return the summary like this: <pre>200 OK application/json 42B 15ms</pre>
+ */
+func (s *ResponseSummary) String() string {
+	line := strconv.Itoa(s.Status)
+	if s.StatusText != "" {
+		line += " " + s.StatusText
+	}
+	if s.ContentType != "" {
+		line += " " + s.ContentType
+	}
+	if s.ContentLength >= 0 {
+		line += " " + strconv.FormatInt(s.ContentLength, 10) + "B"
+	}
+	return fmt.Sprintf("%s %s", line, s.Elapsed.Round(time.Millisecond))
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseSummary(t *testing.T) {
+	response := &http.Response{StatusCode: 201, Status: "201 Created", Header: http.Header{}, Body: io.NopCloser(strings.NewReader("{}"))}
+	response.Header.Set(CONTENT_TYPE, "application/json")
+	response.Header.Set(CONTENT_LENGTH, " 2 ")
+	summary := NewResponseSummary(NewHttpRawResponse(response, NewDefaultConfig(), 0), 15400*time.Microsecond)
+
+	if summary.GetStatus() != 201 || summary.GetContentType() != "application/json" || summary.GetContentLength() != 2 {
+		t.Errorf("got %+v", summary)
+	}
+	if line := summary.String(); line != "201 Created application/json 2B 15ms" {
+		t.Errorf("String() = %q", line)
+	}
+}
+
+func TestParseContentLength(t *testing.T) {
+	tests := map[string]int64{"0": 0, "42": 42, "": -1, "-5": -1, "12abc": -1}
+	for value, expected := range tests {
+		if length := parseContentLength(value); length != expected {
+			t.Errorf("%q: got %d", value, length)
+		}
+	}
+	summary := &ResponseSummary{Status: 204, ContentLength: -1, Elapsed: time.Second}
+	if line := summary.String(); line != "204 1s" {
+		t.Errorf("String() = %q", line)
+	}
+}
@@ -1,6 +1,15 @@
 package main
 
+import (
+	"net/http"
+	"strconv"
+)
+
 const (
+	CONTINUE                        = 100
+	SWITCHING_PROTOCOLS             = 101
+	PROCESSING                      = 102
+	EARLY_HINTS                     = 103
 	OK                              = 200
 	CREATED                         = 201
 	ACCEPTED                        = 202
@@ -61,3 +70,60 @@ const (
 	NOT_EXTENDED                    = 510
 	NETWORK_AUTHENTICATION_REQUIRED = 511
 )
+
+/**
+ * An HTTP status code. The constants above are untyped so they compare with GetStatus() as well as with a StatusCode.
+ */
+type StatusCode int
+
+/**
+ * @return the reason phrase of the status code, e.g. "Not Found", or an empty string for unknown codes
+ */
+func (s StatusCode) Text() string {
+	return http.StatusText(int(s))
+}
+
+/**
+ * @return the code and the reason phrase, e.g. "404 Not Found"
+ */
+func (s StatusCode) String() string {
+	if text := s.Text(); text != "" {
+		return strconv.Itoa(int(s)) + " " + text
+	}
+	return strconv.Itoa(int(s))
+}
+
+/**
+ * @return true for 1xx codes
+ */
+func (s StatusCode) IsInformational() bool {
+	return s >= 100 && s < 200
+}
+
+/**
+ * @return true for 2xx codes
+ */
+func (s StatusCode) IsSuccess() bool {
+	return s >= 200 && s < 300
+}
+
+/**
+ * @return true for 3xx codes
+ */
+func (s StatusCode) IsRedirect() bool {
+	return s >= 300 && s < 400
+}
+
+/**
+ * @return true for 4xx codes
+ */
+func (s StatusCode) IsClientError() bool {
+	return s >= 400 && s < 500
+}
+
+/**
+ * @return true for 5xx codes
+ */
+func (s StatusCode) IsServerError() bool {
+	return s >= 500 && s < 600
+}
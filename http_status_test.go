@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusCodeClasses(t *testing.T) {
+	cases := map[StatusCode][5]bool{
+		100: {true, false, false, false, false},
+		204: {false, true, false, false, false},
+		304: {false, false, true, false, false},
+		404: {false, false, false, true, false},
+		503: {false, false, false, false, true},
+		99:  {},
+		600: {},
+	}
+	for code, want := range cases {
+		got := [5]bool{code.IsInformational(), code.IsSuccess(), code.IsRedirect(), code.IsClientError(), code.IsServerError()}
+		if got != want {
+			t.Errorf("%d: got %v, want %v", code, got, want)
+		}
+	}
+	if StatusCode(NOT_FOUND).String() != "404 Not Found" || StatusCode(599).String() != "599" {
+		t.Errorf("got %s and %s", StatusCode(NOT_FOUND).String(), StatusCode(599).String())
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	if err := NewStatusError(&clientResponse{status: 201}, nil); err != nil {
+		t.Errorf("2xx became %v", err)
+	}
+	response := &clientResponse{status: http.StatusServiceUnavailable, body: "  " + strings.Repeat("down ", 200)}
+	err := NewStatusError(response, nil)
+	var status *StatusError
+	if !errors.As(err, &status) || !IsRetryable(err) || status.StatusText != "Service Unavailable" {
+		t.Fatalf("got %v", err)
+	}
+	if len(status.Body) > DEFAULT_STATUS_ERROR_SNIPPET+len("...") || !strings.HasPrefix(status.Body, "down") {
+		t.Errorf("body snippet %q", status.Body)
+	}
+	if IsRetryable(NewStatusError(&clientResponse{status: http.StatusBadRequest}, nil)) {
+		t.Error("a 400 is retryable")
+	}
+}
+
+func TestHttpRawResponseStatusText(t *testing.T) {
+	cases := map[string]string{"404 Gone Fishing": "Gone Fishing", "404": "Not Found", "": "Not Found"}
+	for status, want := range cases {
+		raw := NewHttpRawResponse(&http.Response{StatusCode: 404, Status: status, Header: http.Header{}}, nil, 0)
+		if got := raw.GetStatusText(); got != want {
+			t.Errorf("%q: got %q", status, got)
+		}
+	}
+}
+
+func TestBodySnippetTruncatesOnRuneBoundary(t *testing.T) {
+	snippet := bodySnippet([]byte("  日本語  "), 4)
+	if snippet != "日..." {
+		t.Errorf("got %q", snippet)
+	}
+	if snippet := bodySnippet(map[string]int{"a": 1}, 100); snippet != `{"a":1}` {
+		t.Errorf("object not encoded as JSON: %q", snippet)
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+import "context"
+
 type Interceptor interface {
 
 	/**
@@ -26,12 +28,14 @@ type Interceptor interface {
 	/**
 	 * Called in the case of a total failure.
 	 * This would be where Unirest was completely unable to make a request at all for reasons like:
-	 *      - DNS errors
-	 *      - Connection failure
-	 *      - Connection or Socket timeout
-	 *      - SSL/TLS errors
+	 *      - DNS errors (DNSError)
+	 *      - Connection failure (ConnectError)
+	 *      - Connection or Socket timeout (TimeoutError)
+	 *      - SSL/TLS errors (TLSError)
+	 *      - Malformed or truncated responses (ProtocolError)
+	 * Use errors.As to tell them apart and IsRetryable to see if sending the request again could help.
 	 *
-	 * The default implimentation simply wraps the exception in a UnirestException and throws it.
+	 * The default implimentation simply returns the error.
 	 * It is possible to return a different response object from the original if you really
 	 * didn't want to every throw exceptions. Keep in mind that this is a lie
 	 *
@@ -44,3 +48,41 @@ type Interceptor interface {
 	 */
 	OnFail(e error, request HttpRequestSummary, config Config) (HttpResponse, error)
 }
+
+/**
+ * An Interceptor which also wants the context of the request, for example to read
+ * trace ids or to log through a context aware handler. When an interceptor implements
+ * this interface the context variants are called instead of the plain ones.
+ */
+type ContextInterceptor interface {
+	Interceptor
+
+	OnRequestContext(ctx context.Context, request HttpRequest, config Config)
+
+	OnResponseContext(ctx context.Context, response HttpResponse, request HttpRequestSummary, config Config)
+
+	OnFailContext(ctx context.Context, e error, request HttpRequestSummary, config Config) (HttpResponse, error)
+}
+
+func interceptRequest(ctx context.Context, interceptor Interceptor, request HttpRequest, config Config) {
+	if i, ok := interceptor.(ContextInterceptor); ok {
+		i.OnRequestContext(ctx, request, config)
+		return
+	}
+	interceptor.OnRequest(request, config)
+}
+
+func interceptResponse(ctx context.Context, interceptor Interceptor, response HttpResponse, request HttpRequestSummary, config Config) {
+	if i, ok := interceptor.(ContextInterceptor); ok {
+		i.OnResponseContext(ctx, response, request, config)
+		return
+	}
+	interceptor.OnResponse(response, request, config)
+}
+
+func interceptFail(ctx context.Context, interceptor Interceptor, e error, request HttpRequestSummary, config Config) (HttpResponse, error) {
+	if i, ok := interceptor.(ContextInterceptor); ok {
+		return i.OnFailContext(ctx, e, request, config)
+	}
+	return interceptor.OnFail(e, request, config)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+/**
+ * A parsed JSON body, the body of AsJson. It holds either an object or an array
+ */
+type JsonNode struct {
+	object map[string]interface{}
+	array  []interface{}
+}
+
+/**
+ * @param text a JSON object or array. An empty text is an empty object
+ * @return the node, or the error of parsing text
+ */
+func NewJsonNode(text string) (*JsonNode, error) {
+	var node = new(JsonNode)
+	text = strings.TrimSpace(text)
+	if text == "" {
+		node.object = make(map[string]interface{})
+		return node, nil
+	}
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(text), &parsed); err != nil {
+		return nil, err
+	}
+	switch value := parsed.(type) {
+	case map[string]interface{}:
+		node.object = value
+	case []interface{}:
+		node.array = value
+	default:
+		// a single value like a string or number, kept as an array of one
+		node.array = []interface{}{value}
+	}
+	return node, nil
+}
+
+/**
+ * @return true if the body was an array
+ */
+func (n *JsonNode) IsArray() bool {
+	return n.object == nil
+}
+
+/**
+ * @return the object, nil if the body was an array
+ */
+func (n *JsonNode) GetObject() map[string]interface{} {
+	return n.object
+}
+
+/**
+ * @return the array, an array holding the object if the body was an object
+ */
+func (n *JsonNode) GetArray() []interface{} {
+	if n.IsArray() {
+		return n.array
+	}
+	return []interface{}{n.object}
+}
+
+func (n *JsonNode) String() string {
+	encoded, _ := json.Marshal(n.value())
+	return string(encoded)
+}
+
+/**
+ * @return the JSON indented by two spaces
+ */
+func (n *JsonNode) ToPrettyString() string {
+	encoded, _ := json.MarshalIndent(n.value(), "", "  ")
+	return string(encoded)
+}
+
+func (n *JsonNode) value() interface{} {
+	if n.IsArray() {
+		return n.array
+	}
+	return n.object
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestJsonNodeObject(t *testing.T) {
+	node, err := NewJsonNode(`{"name":"fifty","tags":["a","b"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.IsArray() || node.GetObject()["name"] != "fifty" {
+		t.Fatalf("unexpected node %v", node)
+	}
+	if node.String() != `{"name":"fifty","tags":["a","b"]}` {
+		t.Errorf("String() = %s", node.String())
+	}
+}
+
+func TestJsonNodeArray(t *testing.T) {
+	node, err := NewJsonNode(`[1, 2]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.IsArray() || len(node.GetArray()) != 2 || node.GetObject() != nil {
+		t.Fatalf("unexpected node %v", node)
+	}
+}
+
+func TestJsonNodeInvalid(t *testing.T) {
+	if _, err := NewJsonNode(`{"name":`); err == nil {
+		t.Fatal("expected a parse error")
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+/**
+ * A single link of a RFC 8288 Link header
+ */
+type Link struct {
+	Url    string
+	Rel    []string
+	Params map[string]string
+}
+
+/**
+ * @param rel a relation type such as "next"
+ * @return true if the link has the relation type, compared case-insensitively
+ */
+func (l Link) HasRel(rel string) bool {
+	return containsFold(l.Rel, rel)
+}
+
+/**
+ * Parse the value of a Link header, for example
+ * <pre>&lt;https://api.example.com/items?page=2&gt;; rel="next", &lt;https://api.example.com/items?page=9&gt;; rel="last"</pre>
+ * Malformed links are skipped.
+ * @param value the header value
+ * @return the links in the order they appear
+ */
+func ParseLinkHeader(value string) []Link {
+	links := make([]Link, 0)
+	rest := value
+	for {
+		start := strings.IndexByte(rest, '<')
+		if start < 0 {
+			return links
+		}
+		end := strings.IndexByte(rest[start:], '>')
+		if end < 0 {
+			return links
+		}
+		var link Link
+		link.Url = strings.TrimSpace(rest[start+1 : start+end])
+		link.Params = make(map[string]string)
+		rest = rest[start+end+1:]
+
+		// the params run until the next link, which starts after a comma outside of quotes
+		params, remaining := splitLinkParams(rest)
+		rest = remaining
+		for _, param := range params {
+			name, val, _ := strings.Cut(param, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			val = strings.Trim(strings.TrimSpace(val), `"`)
+			if name == "" {
+				continue
+			}
+			if name == "rel" {
+				link.Rel = strings.Fields(val)
+			} else {
+				link.Params[name] = val
+			}
+		}
+		links = append(links, link)
+	}
+}
+
+func splitLinkParams(value string) ([]string, string) {
+	params := make([]string, 0)
+	quoted := false
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				params = append(params, value[start:i])
+				start = i + 1
+			}
+		case ',':
+			if !quoted {
+				return append(params, value[start:i]), value[i+1:]
+			}
+		}
+	}
+	return append(params, value[start:]), ""
+}
+
+/**
+ * A LinkExtractor following the rel="next" link of the Link header
+ * @param response the current page
+ * @return the next link or an empty string
+ */
+func NextLinkFromHeader(response HttpResponse) string {
+	headers := response.GetHeaders()
+	for _, value := range headers.Get(LINK) {
+		for _, link := range ParseLinkHeader(value) {
+			if link.HasRel("next") {
+				return link.Url
+			}
+		}
+	}
+	return ""
+}
+
+/**
+ * A LinkExtractor reading the next link from a field of a JSON body,
+ * for example JsonNextLinkExtractor("links", "next") for <pre>{"links": {"next": "/items?page=2"}}</pre>
+ * @param path the keys leading to the field
+ * @return the extractor
+ */
+func JsonNextLinkExtractor(path ...string) LinkExtractor {
+	return func(response HttpResponse) string {
+		value, ok := jsonBodyField(response.GetBody(), path)
+		if !ok || value == nil {
+			return ""
+		}
+		link, _ := value.(string)
+		return link
+	}
+}
+
+/**
+ * A LinkExtractor for cursor based paging. It reads a cursor from a field of the JSON body
+ * and sets it as a query param on the url of the current page, for example
+ * JsonCursorExtractor(request, "cursor", "meta", "next_cursor") for <pre>{"meta": {"next_cursor": "abc"}}</pre>
+ * An empty or missing cursor ends the paging.
+ * @param request the paged request, used to read the url of the current page
+ * @param param the name of the query param carrying the cursor
+ * @param path the keys leading to the cursor field
+ * @return the extractor
+ */
+func JsonCursorExtractor(request HttpRequest, param string, path ...string) LinkExtractor {
+	return func(response HttpResponse) string {
+		value, ok := jsonBodyField(response.GetBody(), path)
+		if !ok || value == nil {
+			return ""
+		}
+		cursor := fmt.Sprint(value)
+		if number, isNumber := value.(float64); isNumber {
+			cursor = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		if cursor == "" {
+			return ""
+		}
+		current, err := url.Parse(request.GetUrl())
+		if err != nil {
+			return ""
+		}
+		query := current.Query()
+		query.Set(param, cursor)
+		current.RawQuery = query.Encode()
+		return current.String()
+	}
+}
+
+func jsonBodyField(body interface{}, path []string) (interface{}, bool) {
+	var node interface{}
+	switch b := body.(type) {
+	case nil:
+		return nil, false
+	case string:
+		if err := json.Unmarshal([]byte(b), &node); err != nil {
+			return nil, false
+		}
+	case []byte:
+		if err := json.Unmarshal(b, &node); err != nil {
+			return nil, false
+		}
+	case map[string]interface{}:
+		node = b
+	case *JsonNode:
+		node = b.value()
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return nil, false
+		}
+		if err := json.Unmarshal(encoded, &node); err != nil {
+			return nil, false
+		}
+	}
+	for _, key := range path {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if node, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return node, true
+}
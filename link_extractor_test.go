@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLinkHeader(t *testing.T) {
+	links := ParseLinkHeader(`<https://api.example.com/items?page=2>; rel="next prefetch"; title="a; b, c", <https://api.example.com/items?page=9>;REL=last, garbage, <unterminated`)
+	if len(links) != 2 {
+		t.Fatalf("got %+v", links)
+	}
+	if links[0].Url != "https://api.example.com/items?page=2" || !reflect.DeepEqual(links[0].Rel, []string{"next", "prefetch"}) || links[0].Params["title"] != "a; b, c" {
+		t.Errorf("first link %+v", links[0])
+	}
+	if !links[1].HasRel("LAST") || links[1].HasRel("next") {
+		t.Errorf("second link %+v", links[1])
+	}
+	if links := ParseLinkHeader(""); len(links) != 0 {
+		t.Errorf("empty header: %+v", links)
+	}
+}
+
+func TestNextLinkFromHeader(t *testing.T) {
+	headers := NewHeaders()
+	headers.Add(LINK, `</items?page=1>; rel="prev"`)
+	headers.Add(LINK, `</items?page=3>; rel="next"`)
+	if next := NextLinkFromHeader(&clientResponse{headers: *headers}); next != "/items?page=3" {
+		t.Errorf("got %q", next)
+	}
+	if next := NextLinkFromHeader(&clientResponse{headers: *NewHeaders()}); next != "" {
+		t.Errorf("without a Link header: %q", next)
+	}
+}
+
+func TestJsonNextLinkExtractor(t *testing.T) {
+	extractor := JsonNextLinkExtractor("links", "next")
+	tests := map[string]string{
+		`{"links":{"next":"/items?page=2"}}`: "/items?page=2",
+		`{"links":{"next":null}}`:            "",
+		`{"links":{}}`:                       "",
+		`{"links":"flat"}`:                   "",
+		`not json`:                           "",
+	}
+	for body, expected := range tests {
+		if next := extractor(&clientResponse{body: body}); next != expected {
+			t.Errorf("%s: got %q", body, next)
+		}
+	}
+	type page struct {
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	}
+	var mapped page
+	mapped.Links.Next = "/items?page=5"
+	if next := extractor(&BaseResponse{body: mapped}); next != "/items?page=5" {
+		t.Errorf("from a mapped body: %q", next)
+	}
+}
+
+func TestJsonCursorExtractor(t *testing.T) {
+	request := newClientRequest(HttpMethodGet, "https://api.example.com/items?limit=10&cursor=old")
+	extractor := JsonCursorExtractor(request, "cursor", "meta", "next_cursor")
+	if next := extractor(&clientResponse{body: `{"meta":{"next_cursor":"a b"}}`}); next != "https://api.example.com/items?cursor=a+b&limit=10" {
+		t.Errorf("got %q", next)
+	}
+	if next := extractor(&clientResponse{body: `{"meta":{"next_cursor":12345678901}}`}); next != "https://api.example.com/items?cursor=12345678901&limit=10" {
+		t.Errorf("a numeric cursor: %q", next)
+	}
+	for _, body := range []string{`{"meta":{"next_cursor":""}}`, `{"meta":{}}`, `{"meta":{"next_cursor":null}}`} {
+		if next := extractor(&clientResponse{body: body}); next != "" {
+			t.Errorf("%s: got %q", body, next)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+type LogVerbosity int
+
+const (
+	// log only the request and status line
+	LogLine LogVerbosity = iota
+	// log the request and status line plus headers
+	LogHeaders
+	// log the request and status line, headers and bodies
+	LogBodies
+)
+
+const (
+	REDACTED                = "[REDACTED]"
+	DEFAULT_MAX_LOGGED_BODY = 4096
+)
+
+/**
+ * An Interceptor which logs every request and response through log/slog.
+ * Sensitive headers and JSON fields are replaced with [REDACTED] before they are written.
+ */
+type LoggingInterceptor struct {
+	Logger *slog.Logger
+	// the level used for requests and responses. Failures are always logged at slog.LevelError
+	Level     slog.Level
+	Verbosity LogVerbosity
+	// bodies larger than this many bytes are truncated, a value <= 0 disables truncation
+	MaxBodySize int
+	// header names to redact, compared case-insensitively
+	RedactHeaders []string
+	// JSON object keys to redact at any depth of the body, compared case-insensitively
+	RedactJsonFields []string
+}
+
+/**
+ * Create a LoggingInterceptor which logs the request and status lines at slog.LevelInfo
+ * and redacts the Authorization, Proxy-Authorization, Cookie and Set-Cookie headers.
+ * @param logger the logger to write to, slog.Default() is used when nil
+ * @return the interceptor
+ */
+func NewLoggingInterceptor(logger *slog.Logger) *LoggingInterceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var interceptor = new(LoggingInterceptor)
+	interceptor.Logger = logger
+	interceptor.Level = slog.LevelInfo
+	interceptor.Verbosity = LogLine
+	interceptor.MaxBodySize = DEFAULT_MAX_LOGGED_BODY
+	interceptor.RedactHeaders = []string{AUTHORIZATION, PROXY_AUTHORIZATION, "Cookie", "Set-Cookie"}
+	interceptor.RedactJsonFields = make([]string, 0)
+	return interceptor
+}
+
+func (l *LoggingInterceptor) OnRequest(request HttpRequest, config Config) {
+	l.OnRequestContext(context.Background(), request, config)
+}
+
+func (l *LoggingInterceptor) OnRequestContext(ctx context.Context, request HttpRequest, config Config) {
+	attrs := []interface{}{"method", request.getHttpMethod(), "url", request.GetUrl()}
+	if l.Verbosity >= LogHeaders {
+		attrs = append(attrs, l.headersAttr(request.GetHeaders()))
+	}
+	if l.Verbosity >= LogBodies {
+		if body := request.getBody(); body != nil {
+			attrs = append(attrs, "body", l.formatBody(body))
+		}
+	}
+	l.Logger.Log(ctx, l.Level, "http request", attrs...)
+}
+
+func (l *LoggingInterceptor) OnResponse(response HttpResponse, request HttpRequestSummary, config Config) {
+	l.OnResponseContext(context.Background(), response, request, config)
+}
+
+func (l *LoggingInterceptor) OnResponseContext(ctx context.Context, response HttpResponse, request HttpRequestSummary, config Config) {
+	attrs := []interface{}{"status", response.GetStatus()}
+	if request != nil {
+		attrs = append(attrs, "method", request.GetHttpMethod(), "url", request.GetUrl())
+	}
+	if l.Verbosity >= LogHeaders {
+		attrs = append(attrs, l.headersAttr(response.GetHeaders()))
+	}
+	if l.Verbosity >= LogBodies {
+		attrs = append(attrs, "body", l.formatBody(response.GetBody()))
+	}
+	l.Logger.Log(ctx, l.Level, "http response", attrs...)
+}
+
+func (l *LoggingInterceptor) OnFail(e error, request HttpRequestSummary, config Config) (HttpResponse, error) {
+	return l.OnFailContext(context.Background(), e, request, config)
+}
+
+func (l *LoggingInterceptor) OnFailContext(ctx context.Context, e error, request HttpRequestSummary, config Config) (HttpResponse, error) {
+	attrs := []interface{}{"error", e}
+	if request != nil {
+		attrs = append(attrs, "method", request.GetHttpMethod(), "url", request.GetUrl())
+	}
+	l.Logger.Log(ctx, slog.LevelError, "http request failed", attrs...)
+	return nil, e
+}
+
+func (l *LoggingInterceptor) headersAttr(headers Headers) slog.Attr {
+	attrs := make([]interface{}, 0, len(headers.Headers))
+	for _, header := range headers.Headers {
+		value := header.GetValue()
+		if containsFold(l.RedactHeaders, header.GetName()) {
+			value = REDACTED
+		}
+		attrs = append(attrs, slog.String(header.GetName(), value))
+	}
+	return slog.Group("headers", attrs...)
+}
+
+func (l *LoggingInterceptor) formatBody(body interface{}) string {
+	return l.truncate(l.redactJson(bodyText(body)))
+}
+
+func (l *LoggingInterceptor) redactJson(text string) string {
+	if len(l.RedactJsonFields) == 0 {
+		return text
+	}
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return text
+	}
+	var node interface{}
+	if err := json.Unmarshal([]byte(trimmed), &node); err != nil {
+		return text
+	}
+	redacted, err := json.Marshal(l.redactNode(node))
+	if err != nil {
+		return text
+	}
+	return string(redacted)
+}
+
+func (l *LoggingInterceptor) redactNode(node interface{}) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if containsFold(l.RedactJsonFields, key) {
+				n[key] = REDACTED
+			} else {
+				n[key] = l.redactNode(value)
+			}
+		}
+	case []interface{}:
+		for i, value := range n {
+			n[i] = l.redactNode(value)
+		}
+	}
+	return node
+}
+
+func (l *LoggingInterceptor) truncate(text string) string {
+	kept, dropped := truncateText(text, l.MaxBodySize)
+	if dropped == 0 {
+		return text
+	}
+	return fmt.Sprintf("%s...(%d bytes truncated)", kept, dropped)
+}
+
+/**
+ * Turn a response or request body into text for logs and errors. Objects are encoded as JSON
+ * @param body the body, may be nil
+ * @return the text
+ */
+func bodyText(body interface{}) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return b
+	case []byte:
+		return string(b)
+	case fmt.Stringer:
+		return b.String()
+	}
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Sprint(body)
+	}
+	return string(encoded)
+}
+
+/**
+ * Cut text to at most max bytes without splitting a multi-byte character
+ * @param text the text
+ * @param max the number of bytes to keep, <= 0 keeps everything
+ * @return the kept text and the number of bytes cut off
+ */
+func truncateText(text string, max int) (string, int) {
+	if max <= 0 || len(text) <= max {
+		return text, 0
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut], len(text) - cut
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
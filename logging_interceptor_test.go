@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLoggingInterceptorLogsRequestsOfTheClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(CONTENT_TYPE, string(APPLICATION_JSON))
+		io.WriteString(w, `{"token":"s3cr3t","id":7}`)
+	}))
+	defer server.Close()
+	var logged bytes.Buffer
+	var interceptor = NewLoggingInterceptor(slog.New(slog.NewTextHandler(&logged, nil)))
+	interceptor.Verbosity = LogBodies
+	interceptor.RedactJsonFields = []string{"token"}
+	config := NewDefaultConfig()
+	config.AddInterceptor(interceptor)
+	client := newTestClient(t, config)
+
+	response := client.Post(server.URL+"/users").WithBody(NewBytesBody([]byte(`{"password":"hunter2"}`), string(APPLICATION_JSON))).
+		BasicAuth("ann", "hunter2").AsString()
+	if !response.IsSuccess() {
+		t.Fatal(response.GetParsingError())
+	}
+	lines := strings.Split(strings.TrimSpace(logged.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `msg="http request" method=POST url=`+server.URL+"/users") ||
+		!strings.Contains(lines[1], `msg="http response" status=200 method=POST`) {
+		t.Fatalf("logged:\n%s", logged.String())
+	}
+	if strings.Contains(logged.String(), "s3cr3t") || !strings.Contains(lines[0], "Authorization="+REDACTED) || !strings.Contains(lines[1], `\"id\":7`) {
+		t.Errorf("not redacted:\n%s", logged.String())
+	}
+
+	server.Close()
+	logged.Reset()
+	client.Get(server.URL).AsString()
+	if !strings.Contains(logged.String(), `level=ERROR msg="http request failed" error=`) {
+		t.Errorf("failure not logged:\n%s", logged.String())
+	}
+}
+
+func TestLoggingInterceptorRedactsJsonFields(t *testing.T) {
+	var interceptor = NewLoggingInterceptor(nil)
+	interceptor.RedactJsonFields = []string{"password"}
+
+	logged := interceptor.formatBody(`{"user":"ann","nested":{"Password":"secret"}}`)
+	if strings.Contains(logged, "secret") || !strings.Contains(logged, REDACTED) {
+		t.Errorf("password not redacted: %s", logged)
+	}
+	if !strings.Contains(logged, `"user":"ann"`) {
+		t.Errorf("other fields lost: %s", logged)
+	}
+}
+
+func TestLoggingInterceptorTruncatesOnRuneBoundary(t *testing.T) {
+	var interceptor = NewLoggingInterceptor(nil)
+	interceptor.MaxBodySize = 5
+
+	logged := interceptor.formatBody("ääää")
+	if !utf8.ValidString(logged) {
+		t.Fatalf("truncated body is not valid UTF-8: %q", logged)
+	}
+	if logged != "ää...(4 bytes truncated)" {
+		t.Errorf("got %q", logged)
+	}
+	if logged := interceptor.formatBody("short"); logged != "short" {
+		t.Errorf("body within the limit changed: %q", logged)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/**
+ * Chooses the ObjectMapper for a body by its Content-Type and builds the Accept header from the
+ * content types it can decode. Patterns may use wildcards like application/*+json, see MediaType.Matches.
+ */
+type MapperRegistry struct {
+	lock    sync.RWMutex
+	entries []mapperEntry
+}
+
+type mapperEntry struct {
+	contentType ContentType
+	pattern     MediaType
+	mapper      ObjectMapper
+	quality     float64
+}
+
+/**
+ * @return a registry without mappers
+ */
+func NewMapperRegistry() *MapperRegistry {
+	return new(MapperRegistry)
+}
+
+/**
+ * @return a registry for JSON, XML, NDJSON, CBOR and form bodies, JSON preferred.
+ * application/*+json and application/*+xml bodies are decoded too but not listed in the Accept header
+ */
+func NewDefaultMapperRegistry() *MapperRegistry {
+	var registry = NewMapperRegistry()
+	jsonMapper := NewJsonObjectMapper()
+	xmlMapper := new(XmlObjectMapper)
+	defaults := []mapperEntry{
+		{contentType: APPLICATION_JSON, mapper: jsonMapper, quality: 1},
+		{contentType: "application/*+json", mapper: jsonMapper},
+		{contentType: APPLICATION_XML, mapper: xmlMapper, quality: 0.8},
+		{contentType: TEXT_XML, mapper: xmlMapper, quality: 0.8},
+		{contentType: "application/*+xml", mapper: xmlMapper},
+		{contentType: APPLICATION_NDJSON, mapper: new(NdjsonObjectMapper), quality: 0.6},
+		{contentType: APPLICATION_CBOR, mapper: new(CborObjectMapper), quality: 0.5},
+		{contentType: APPLICATION_FORM_URLENCODED, mapper: new(FormObjectMapper), quality: 0.4},
+	}
+	for _, entry := range defaults {
+		if err := registry.Register(entry.contentType, entry.mapper, entry.quality); err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+/**
+ * Register a mapper, replacing the one registered for the same content type
+ * @param contentType the content type or pattern the mapper decodes
+ * @param mapper the mapper
+ * @param quality the q value of the content type in the Accept header, between 0 and 1. 0 leaves it out of the header,
+ * so do suffix patterns like application/*+json which are not media ranges an Accept header may carry
+ * @return an error if contentType does not parse or quality is out of range
+ */
+func (r *MapperRegistry) Register(contentType ContentType, mapper ObjectMapper, quality float64) error {
+	pattern, err := contentType.Parse()
+	if err != nil {
+		return err
+	}
+	if quality < 0 || quality > 1 {
+		return fmt.Errorf("fiftyrest: quality %v of %s is not between 0 and 1", quality, contentType)
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	entry := mapperEntry{contentType: contentType, pattern: pattern, mapper: mapper, quality: quality}
+	for i, existing := range r.entries {
+		if existing.pattern.Essence() == pattern.Essence() {
+			r.entries[i] = entry
+			return nil
+		}
+	}
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+/**
+ * Find the mapper for a body. Exact content types win over suffix patterns like application/*+json,
+ * which win over type patterns like application/*, which win over the full wildcard.
+ * @param media the Content-Type of the body
+ * @return the mapper, false when none matches
+ */
+func (r *MapperRegistry) Lookup(media MediaType) (ObjectMapper, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	var found *mapperEntry
+	for i := range r.entries {
+		entry := &r.entries[i]
+		if media.Matches(entry.pattern) && (found == nil || specificity(entry.pattern) > specificity(found.pattern)) {
+			found = entry
+		}
+	}
+	if found == nil {
+		return nil, false
+	}
+	return found.mapper, true
+}
+
+func specificity(pattern MediaType) int {
+	switch {
+	case pattern.Type == "*":
+		return 0
+	case pattern.Subtype == "*":
+		return 1
+	case strings.HasPrefix(pattern.Subtype, "*+"):
+		return 2
+	}
+	return 3
+}
+
+/**
+ * @return an Accept header listing the registered content types and type/* ranges by quality, e.g.
+ * <pre>application/json, application/xml;q=0.8, text/xml;q=0.8</pre>
+ */
+func (r *MapperRegistry) Accept() string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	header := NewAcceptHeader()
+	for _, entry := range r.entries {
+		if entry.quality > 0 && specificity(entry.pattern) != 2 {
+			header.Add(entry.pattern.Essence(), entry.quality)
+		}
+	}
+	return header.String()
+}
+
+/**
+ * Send the Accept header of the registry unless the caller already chose one.
+ * Only AsObject decodes with the registry, so only its requests get this header
+ * @param headers the request headers
+ * @param registry the registry of the config
+ */
+func negotiateAccept(headers *Headers, registry *MapperRegistry) {
+	if registry == nil || headers.ContainsKey(ACCEPT) {
+		return
+	}
+	if accept := registry.Accept(); accept != "" {
+		headers.Add(ACCEPT, accept)
+	}
+}
+
+/**
+ * Pick the ObjectMapper for a response: the one set on the request with WithObjectMapper,
+ * then the one the registry of the config has for the Content-Type, then Config.ObjectMapper
+ * @param config the config
+ * @param requestMapper the mapper of the request, may be nil
+ * @param raw the response
+ * @return the mapper
+ */
+func selectObjectMapper(config *Config, requestMapper ObjectMapper, raw RawResponse) ObjectMapper {
+	if requestMapper != nil {
+		return requestMapper
+	}
+	if config.Mappers != nil {
+		if mapper, ok := config.Mappers.Lookup(raw.GetMediaType()); ok {
+			return mapper
+		}
+	}
+	return config.GetObjectMapper()
+}
+
+/**
+ * Pick the ObjectMapper for the body of a failure response: the one the registry of the config has for
+ * its Content-Type, then the one set on the request, then Config.ObjectMapper. The Content-Type comes first
+ * because the mapper of the request was chosen for the success body, an error body is often of another type
+ * @param config the config, may be nil
+ * @param requestMapper the mapper of the request, may be nil
+ * @param contentType the Content-Type of the body
+ * @return the mapper
+ */
+func selectErrorMapper(config *Config, requestMapper ObjectMapper, contentType string) ObjectMapper {
+	if config != nil && config.Mappers != nil {
+		if media, err := ParseContentType(contentType); err == nil {
+			if mapper, ok := config.Mappers.Lookup(media); ok {
+				return mapper
+			}
+		}
+	}
+	if requestMapper != nil {
+		return requestMapper
+	}
+	if config == nil {
+		return NewJsonObjectMapper()
+	}
+	return config.GetObjectMapper()
+}
+
+/**
+ * Decode the body of a response with the mapper selectObjectMapper picks. The body is decoded with its charset
+ * first unless the mapper is a BinaryObjectMapper, which gets the bytes as they were received
+ * @param config the config
+ * @param requestMapper the mapper of the request, may be nil
+ * @param raw the response
+ * @param target a pointer to the value to decode into
+ * @return a BodyParseError keeping the original body when decoding failed
+ */
+func decodeBody(config *Config, requestMapper ObjectMapper, raw RawResponse, target interface{}) error {
+	mapper := selectObjectMapper(config, requestMapper, raw)
+	var body string
+	var err error
+	if binary, ok := mapper.(BinaryObjectMapper); ok {
+		content := raw.GetContentAsBytes()
+		body = string(content)
+		err = binary.ReadBytes(content, target)
+	} else {
+		body = raw.GetContentAsString()
+		err = mapper.ReadValue(body, target)
+	}
+	if err != nil {
+		return &BodyParseError{ContentType: raw.GetContentType(), OriginalBody: body, Cause: err}
+	}
+	return nil
+}
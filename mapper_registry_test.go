@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func rawResponseOf(contentType ContentType, body []byte) *HttpRawResponse {
+	response := &http.Response{StatusCode: 200, Header: http.Header{CONTENT_TYPE: {string(contentType)}}, Body: io.NopCloser(bytes.NewReader(body))}
+	return NewHttpRawResponse(response, NewDefaultConfig(), 0)
+}
+
+func TestMapperRegistryAcceptListsOnlyMediaRanges(t *testing.T) {
+	accept := NewDefaultMapperRegistry().Accept()
+	if strings.Contains(accept, "*+") {
+		t.Errorf("suffix pattern in Accept: %s", accept)
+	}
+	if !strings.HasPrefix(accept, string(APPLICATION_JSON)+", ") {
+		t.Errorf("JSON not preferred: %s", accept)
+	}
+
+	var registry = NewMapperRegistry()
+	if err := registry.Register("application/*", new(JsonObjectMapper), 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("application/*+json", new(JsonObjectMapper), 0.9); err != nil {
+		t.Fatal(err)
+	}
+	if accept := registry.Accept(); accept != "application/*;q=0.5" {
+		t.Errorf("got %s", accept)
+	}
+}
+
+func TestMapperRegistryRejectsBadRegistrations(t *testing.T) {
+	var registry = NewMapperRegistry()
+	if err := registry.Register("not a type", new(JsonObjectMapper), 1); err == nil {
+		t.Error("unparsable content type registered")
+	}
+	if err := registry.Register(APPLICATION_JSON, new(JsonObjectMapper), 1.5); err == nil {
+		t.Error("quality above 1 registered")
+	}
+}
+
+func TestMapperRegistryLookupPrefersSpecificPatterns(t *testing.T) {
+	registry := NewDefaultMapperRegistry()
+	cases := map[ContentType]ObjectMapper{
+		"application/json; charset=utf-8": new(JsonObjectMapper),
+		"application/problem+json":        new(JsonObjectMapper),
+		"application/atom+xml":            new(XmlObjectMapper),
+		APPLICATION_CBOR:                  new(CborObjectMapper),
+	}
+	for contentType, want := range cases {
+		media, err := contentType.Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mapper, ok := registry.Lookup(media)
+		if !ok || fmt.Sprintf("%T", mapper) != fmt.Sprintf("%T", want) {
+			t.Errorf("%s: got %T", contentType, mapper)
+		}
+	}
+	if _, ok := registry.Lookup(MediaType{Type: "image", Subtype: "png"}); ok {
+		t.Error("image/png has a mapper")
+	}
+}
+
+func TestDecodeBodyHandsBinaryMappersTheBytes(t *testing.T) {
+	// 0xff is not valid UTF-8 and would become U+FFFD if the body went through a charset
+	want := map[string][]byte{"blob": {0xff, 0xfe, 0x00}}
+	encoded, err := cbor.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string][]byte
+	if err := decodeBody(NewDefaultConfig(), nil, rawResponseOf(APPLICATION_CBOR, encoded), &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got["blob"], want["blob"]) {
+		t.Errorf("got %x", got["blob"])
+	}
+}
+
+func TestDecodeBodyDecodesTextWithItsCharset(t *testing.T) {
+	var got map[string]string
+	raw := rawResponseOf("application/json; charset=iso-8859-1", []byte("{\"name\":\"Jos\xe9\"}"))
+	if err := decodeBody(NewDefaultConfig(), nil, raw, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["name"] != "José" {
+		t.Errorf("got %q", got["name"])
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+/**
+ * Maps bodies to and from objects. Used by AsObject, by bodies passed as objects and by MapError
+ */
+type ObjectMapper interface {
+
+	/**
+	 * Decode a body
+	 * @param value the body
+	 * @param target a pointer to the value to decode into
+	 * @return an error if the body could not be decoded
+	 */
+	ReadValue(value string, target interface{}) error
+
+	/**
+	 * Encode an object into a body
+	 * @param value the object
+	 * @return the body
+	 */
+	WriteValue(value interface{}) (string, error)
+}
+
+/**
+ * Implemented by ObjectMappers for binary formats. They are handed the body as received,
+ * decoding it with a charset first would replace invalid UTF-8 sequences and corrupt it.
+ */
+type BinaryObjectMapper interface {
+	ObjectMapper
+
+	/**
+	 * Decode a body
+	 * @param value the body
+	 * @param target a pointer to the value to decode into
+	 * @return an error if the body could not be decoded
+	 */
+	ReadBytes(value []byte, target interface{}) error
+}
+
+/**
+ * The default ObjectMapper, based on encoding/json
+ */
+type JsonObjectMapper struct {
+	// fail on fields of the body which the target has no field for, default = false
+	DisallowUnknownFields bool
+}
+
+/**
+ * @return a mapper which ignores unknown fields
+ */
+func NewJsonObjectMapper() *JsonObjectMapper {
+	return new(JsonObjectMapper)
+}
+
+func (m *JsonObjectMapper) ReadValue(value string, target interface{}) error {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	if m.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(target)
+}
+
+func (m *JsonObjectMapper) WriteValue(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+/**
+ * An ObjectMapper for application/xml and text/xml, based on encoding/xml
+ */
+type XmlObjectMapper struct {
+}
+
+func (m *XmlObjectMapper) ReadValue(value string, target interface{}) error {
+	return xml.Unmarshal([]byte(value), target)
+}
+
+func (m *XmlObjectMapper) WriteValue(value interface{}) (string, error) {
+	encoded, err := xml.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+/**
+ * An ObjectMapper for newline delimited JSON, application/x-ndjson.
+ * Reads into a pointer to a slice with one element per line and writes slices one element per line.
+ */
+type NdjsonObjectMapper struct {
+}
+
+func (m *NdjsonObjectMapper) ReadValue(value string, target interface{}) error {
+	slice := reflect.ValueOf(target)
+	if slice.Kind() != reflect.Pointer || slice.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("fiftyrest: ndjson needs a pointer to a slice, got %T", target)
+	}
+	slice = slice.Elem()
+	scanner := bufio.NewScanner(strings.NewReader(value))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(value)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		element := reflect.New(slice.Type().Elem())
+		if err := json.Unmarshal(text, element.Interface()); err != nil {
+			return fmt.Errorf("fiftyrest: ndjson line %d: %w", line, err)
+		}
+		slice.Set(reflect.Append(slice, element.Elem()))
+	}
+	return scanner.Err()
+}
+
+func (m *NdjsonObjectMapper) WriteValue(value interface{}) (string, error) {
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice && slice.Kind() != reflect.Array {
+		return "", fmt.Errorf("fiftyrest: ndjson needs a slice, got %T", value)
+	}
+	var lines strings.Builder
+	for i := 0; i < slice.Len(); i++ {
+		encoded, err := json.Marshal(slice.Index(i).Interface())
+		if err != nil {
+			return "", err
+		}
+		lines.Write(encoded)
+		lines.WriteByte('\n')
+	}
+	return lines.String(), nil
+}
+
+/**
+ * An ObjectMapper for application/cbor
+ */
+type CborObjectMapper struct {
+}
+
+func (m *CborObjectMapper) ReadValue(value string, target interface{}) error {
+	return m.ReadBytes([]byte(value), target)
+}
+
+func (m *CborObjectMapper) ReadBytes(value []byte, target interface{}) error {
+	return cbor.Unmarshal(value, target)
+}
+
+func (m *CborObjectMapper) WriteValue(value interface{}) (string, error) {
+	encoded, err := cbor.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+/**
+ * An ObjectMapper for application/x-www-form-urlencoded. Reads into *url.Values, or into maps and structs
+ * by the same rules as JSON with a string for single values and a []string for repeated ones.
+ */
+type FormObjectMapper struct {
+}
+
+func (m *FormObjectMapper) ReadValue(value string, target interface{}) error {
+	values, err := url.ParseQuery(value)
+	if err != nil {
+		return err
+	}
+	if form, ok := target.(*url.Values); ok {
+		*form = values
+		return nil
+	}
+	fields := make(map[string]interface{}, len(values))
+	for name, list := range values {
+		if len(list) == 1 {
+			fields[name] = list[0]
+		} else {
+			fields[name] = list
+		}
+	}
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, target)
+}
+
+func (m *FormObjectMapper) WriteValue(value interface{}) (string, error) {
+	if form, ok := value.(url.Values); ok {
+		return form.Encode(), nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return "", errors.New("fiftyrest: form bodies need a map or a struct")
+	}
+	form := make(url.Values)
+	for name, field := range fields {
+		switch v := field.(type) {
+		case []interface{}:
+			for _, element := range v {
+				form.Add(name, fmt.Sprint(element))
+			}
+		case nil:
+			form.Add(name, "")
+		default:
+			form.Add(name, fmt.Sprint(v))
+		}
+	}
+	return form.Encode(), nil
+}
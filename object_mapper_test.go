@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type mappedItem struct {
+	Name  string   `json:"name" xml:"name"`
+	Count int      `json:"count" xml:"count"`
+	Tags  []string `json:"tags,omitempty" xml:"tag"`
+}
+
+func TestXmlObjectMapperRoundTrip(t *testing.T) {
+	mapper := new(XmlObjectMapper)
+	written, err := mapper.WriteValue(mappedItem{Name: "a", Count: 2, Tags: []string{"x", "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != "<mappedItem><name>a</name><count>2</count><tag>x</tag><tag>y</tag></mappedItem>" {
+		t.Errorf("wrote %s", written)
+	}
+	var item mappedItem
+	if err := mapper.ReadValue(written, &item); err != nil || !reflect.DeepEqual(item, mappedItem{Name: "a", Count: 2, Tags: []string{"x", "y"}}) {
+		t.Errorf("read %+v, %v", item, err)
+	}
+}
+
+func TestNdjsonObjectMapper(t *testing.T) {
+	mapper := new(NdjsonObjectMapper)
+	var items []mappedItem
+	if err := mapper.ReadValue("{\"name\":\"a\",\"count\":1}\n\n  {\"name\":\"b\",\"count\":2}\r\n", &items); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(items, []mappedItem{{Name: "a", Count: 1}, {Name: "b", Count: 2}}) {
+		t.Errorf("read %+v", items)
+	}
+	written, err := mapper.WriteValue(items)
+	if err != nil || written != "{\"name\":\"a\",\"count\":1}\n{\"name\":\"b\",\"count\":2}\n" {
+		t.Errorf("wrote %q, %v", written, err)
+	}
+
+	items = nil
+	if err := mapper.ReadValue("{\"name\":\"a\"}\n{broken\n", &items); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("a broken line: %v", err)
+	}
+	var single mappedItem
+	if err := mapper.ReadValue("{}", &single); err == nil {
+		t.Error("read into a struct")
+	}
+	if _, err := mapper.WriteValue(single); err == nil {
+		t.Error("wrote a struct")
+	}
+	long := "{\"name\":\"" + strings.Repeat("a", 100*1024) + "\"}"
+	items = nil
+	if err := mapper.ReadValue(long, &items); err != nil || len(items) != 1 {
+		t.Errorf("a line longer than the scanner buffer: %v", err)
+	}
+}
+
+func TestCborObjectMapperRoundTrip(t *testing.T) {
+	mapper := new(CborObjectMapper)
+	written, err := mapper.WriteValue(mappedItem{Name: "a", Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var item mappedItem
+	if err := mapper.ReadBytes([]byte(written), &item); err != nil || item.Name != "a" || item.Count != 2 {
+		t.Errorf("ReadBytes %+v, %v", item, err)
+	}
+	item = mappedItem{}
+	if err := mapper.ReadValue(written, &item); err != nil || item.Name != "a" {
+		t.Errorf("ReadValue %+v, %v", item, err)
+	}
+}
+
+func TestFormObjectMapper(t *testing.T) {
+	mapper := new(FormObjectMapper)
+	var item mappedItem
+	if err := mapper.ReadValue("name=a+b&tags=x&tags=y", &item); err != nil || item.Name != "a b" || !reflect.DeepEqual(item.Tags, []string{"x", "y"}) {
+		t.Errorf("read %+v, %v", item, err)
+	}
+	var form url.Values
+	if err := mapper.ReadValue("a=1&a=2&b=", &form); err != nil || !reflect.DeepEqual(form, url.Values{"a": {"1", "2"}, "b": {""}}) {
+		t.Errorf("read %v, %v", form, err)
+	}
+	if err := mapper.ReadValue("a=%zz", &form); err == nil {
+		t.Error("an invalid escape was read")
+	}
+
+	written, err := mapper.WriteValue(mappedItem{Name: "a&b", Count: 3, Tags: []string{"x", "y"}})
+	if err != nil || written != "count=3&name=a%26b&tags=x&tags=y" {
+		t.Errorf("wrote %q, %v", written, err)
+	}
+	written, err = mapper.WriteValue(url.Values{"q": {"go lang"}})
+	if err != nil || written != "q=go+lang" {
+		t.Errorf("wrote %q, %v", written, err)
+	}
+	if _, err := mapper.WriteValue([]string{"a"}); err == nil {
+		t.Error("wrote a slice")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"iter"
+)
+
+/**
+ * Options for iterating over the items of a paged request
+ */
+type PageOptions struct {
+	// the maximum number of pages to request, a value <= 0 means no limit
+	MaxPages int
+	// fetch the next page in the background while the items of the current page are consumed
+	Prefetch bool
+}
+
+/**
+ * Returned by the page iterator when a page was NOT a 200-series response or its body failed to map.
+ * The iteration stops after it.
+ */
+type PageError struct {
+	Page     int
+	Response HttpResponse
+}
+
+func (e *PageError) Error() string {
+	if err := e.Response.GetParsingError(); err != nil {
+		return fmt.Sprintf("fiftyrest: page %d could not be mapped: %v", e.Page, err)
+	}
+	return fmt.Sprintf("fiftyrest: page %d failed with status %d", e.Page, e.Response.GetStatus())
+}
+
+func (e *PageError) Unwrap() error {
+	return e.Response.GetParsingError()
+}
+
+type fetchedPage struct {
+	url  string
+	page HttpResponse
+	next string
+}
+
+/**
+ * The iterator behind Pages, shared by the request implementations.
+ * Pages are requested lazily: the next page is only fetched once every item of the current one was consumed,
+ * or while they are consumed when options.Prefetch is set. A body which is a slice or array yields its elements,
+ * any other body is yielded as a single item. Every range starts at the first page. The request belongs to
+ * the iterator while it is ranged over and points at the first page again afterwards.
+ * @param request the request, reused for every page
+ * @param retarget points the request at the url of the next page
+ * @param mappingFunction executes the request for one page
+ * @param linkExtractor extracts the next link from a page
+ * @param options the paging options
+ * @return an iterator over the items of all pages
+ */
+func iteratePages(request HttpRequest, retarget func(url string), mappingFunction PageMapper, linkExtractor LinkExtractor, options PageOptions) iter.Seq2[interface{}, error] {
+	start := request.GetUrl()
+	fetch := func(url string) fetchedPage {
+		page := mappingFunction(request)
+		return fetchedPage{url: url, page: page, next: resolveLink(url, linkExtractor(page))}
+	}
+
+	return func(yield func(interface{}, error) bool) {
+		parent := request.GetContext()
+		ctx, cancel := context.WithCancel(parent)
+		request.WithContext(ctx)
+		var prefetched chan fetchedPage
+		defer func() {
+			// abort a prefetch the consumer no longer wants and wait for it before handing the request back
+			cancel()
+			if prefetched != nil {
+				<-prefetched
+			}
+			retarget(start)
+			request.WithContext(parent)
+		}()
+
+		visited := make(map[string]bool)
+		retarget(start)
+		fetched := fetch(start)
+		for pages := 1; ; pages++ {
+			visited[fetched.url] = true
+			if !fetched.page.IsSuccess() {
+				yield(nil, &PageError{Page: pages, Response: fetched.page})
+				return
+			}
+
+			hasNext := fetched.next != "" && !visited[fetched.next] && (options.MaxPages <= 0 || pages < options.MaxPages)
+			if hasNext && options.Prefetch {
+				retarget(fetched.next)
+				prefetched = make(chan fetchedPage, 1)
+				go func(url string) {
+					prefetched <- fetch(url)
+				}(fetched.next)
+			}
+
+			for _, item := range flattenBody(fetched.page.GetBody()) {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if !hasNext {
+				return
+			}
+			if prefetched != nil {
+				fetched = <-prefetched
+				prefetched = nil
+			} else {
+				retarget(fetched.next)
+				fetched = fetch(fetched.next)
+			}
+		}
+	}
+}
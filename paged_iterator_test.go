@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type pagingKey struct{}
+
+func TestIteratePagesRestartsEveryRange(t *testing.T) {
+	for _, prefetch := range []bool{false, true} {
+		var server = new(pagingServer)
+		var request = &pagingRequest{url: "http://api/1"}
+		pages := iteratePages(request, request.retarget, server.mapper, pagingNextLink, PageOptions{Prefetch: prefetch})
+
+		for round := 0; round < 2; round++ {
+			items := make([]interface{}, 0)
+			for item, err := range pages {
+				if err != nil {
+					t.Fatalf("prefetch %v: %v", prefetch, err)
+				}
+				items = append(items, item)
+			}
+			if got := joinItems(items); got != "a,b,c,d,e,f" {
+				t.Errorf("prefetch %v, round %d: got %s", prefetch, round, got)
+			}
+			if request.GetUrl() != "http://api/1" {
+				t.Errorf("prefetch %v: request left at %s", prefetch, request.GetUrl())
+			}
+		}
+		if got := len(server.requests()); got != 6 {
+			t.Errorf("prefetch %v: %d pages requested for two ranges over three pages", prefetch, got)
+		}
+	}
+}
+
+func TestIteratePagesCancelsPrefetchOnBreak(t *testing.T) {
+	var server = new(pagingServer)
+	server.slow = true
+	parent := context.WithValue(context.Background(), pagingKey{}, "parent")
+	var request = &pagingRequest{url: "http://api/1", ctx: parent}
+	pages := iteratePages(request, request.retarget, server.mapper, pagingNextLink, PageOptions{Prefetch: true})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for item := range pages {
+			if item == "a" {
+				break
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("breaking out of the range did not cancel the prefetch")
+	}
+	if request.GetUrl() != "http://api/1" || request.GetContext() != parent {
+		t.Errorf("request not restored: %s", request.GetUrl())
+	}
+}
+
+func TestIteratePagesStopsAtFailedPage(t *testing.T) {
+	var server = new(pagingServer)
+	var request = &pagingRequest{url: "http://api/2"}
+	pages := iteratePages(request, request.retarget, func(r HttpRequest) HttpResponse {
+		if r.GetUrl() == "http://api/3" {
+			return &pagingResponse{status: 500}
+		}
+		return server.mapper(r)
+	}, pagingNextLink, PageOptions{})
+
+	items, failures := 0, 0
+	for _, err := range pages {
+		if err != nil {
+			failures++
+			if page, ok := err.(*PageError); !ok || page.Page != 2 {
+				t.Errorf("got %v", err)
+			}
+			continue
+		}
+		items++
+	}
+	if items != 2 || failures != 1 {
+		t.Errorf("got %d items and %d failures", items, failures)
+	}
+}
+
+func TestPagesIteratesOverTheItemsOfTheServer(t *testing.T) {
+	server := newPagedServer(t)
+	client := newTestClient(t, NewDefaultConfig())
+	request := client.Get(server.URL + "/items")
+	asObject := func(r HttpRequest) HttpResponse { return r.AsObject() }
+
+	for _, prefetch := range []bool{false, true} {
+		var items []interface{}
+		for item, err := range request.PagesWithOptions(asObject, NextLinkFromHeader, PageOptions{Prefetch: prefetch}) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, item)
+		}
+		if got := joinItems(items); got != "a,b,c,d,e,f" {
+			t.Errorf("prefetch %v: got %s", prefetch, got)
+		}
+	}
+	for item := range request.Pages(asObject, NextLinkFromHeader) {
+		if item != "a" {
+			t.Errorf("started at %v", item)
+		}
+		break
+	}
+	if request.GetUrl() != server.URL+"/items" {
+		t.Errorf("request left at %s", request.GetUrl())
+	}
+
+	var failed *PageError
+	for _, err := range client.Get(server.URL+"/gone").Pages(asObject, NextLinkFromHeader) {
+		if !errors.As(err, &failed) || failed.Response.GetStatus() != http.StatusGone {
+			t.Errorf("got %v", err)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+)
+
+const DEFAULT_MAX_PAGES = 1000
+
+/**
+ * Executes a request for one page, usually by calling one of the As* methods.
+ */
+type PageMapper func(request HttpRequest) HttpResponse
+
+/**
+ * Extracts the "next" link from a page. Returning an empty string ends the paging.
+ * Relative links are resolved against the url of the page they came from.
+ */
+type LinkExtractor func(response HttpResponse) string
+
+/**
+ * The responses of a paged request, in the order they were requested.
+ */
+type PagedList []HttpResponse
+
+/**
+ * Invoke the consumer for every page which was a 200-series response
+ * @param consumer a function to consume a HttpResponse
+ * @return the same list
+ */
+func (p PagedList) IfSuccess(consumer HttpResponseConsumer) PagedList {
+	for i := range p {
+		p[i].IfSuccess(consumer)
+	}
+	return p
+}
+
+/**
+ * Invoke the consumer for every page which was NOT a 200-series response or failed to map
+ * @param consumer a function to consume a HttpResponse
+ * @return the same list
+ */
+func (p PagedList) IfFailure(consumer HttpResponseConsumer) PagedList {
+	for i := range p {
+		p[i].IfFailure(consumer)
+	}
+	return p
+}
+
+/**
+ * @return the bodies of the successful pages, one per page
+ */
+func (p PagedList) GetBodies() []interface{} {
+	bodies := make([]interface{}, 0, len(p))
+	for _, response := range p {
+		if response.IsSuccess() {
+			bodies = append(bodies, response.GetBody())
+		}
+	}
+	return bodies
+}
+
+/**
+ * @return the bodies of the successful pages where a body which is a slice or array
+ * contributes its elements instead of itself. Useful when every page holds a list of items
+ */
+func (p PagedList) GetFlattenedBodies() []interface{} {
+	items := make([]interface{}, 0, len(p))
+	for _, body := range p.GetBodies() {
+		items = append(items, flattenBody(body)...)
+	}
+	return items
+}
+
+func flattenBody(body interface{}) []interface{} {
+	value := reflect.ValueOf(body)
+	if body == nil || (value.Kind() != reflect.Slice && value.Kind() != reflect.Array) {
+		return []interface{}{body}
+	}
+	if _, isBytes := body.([]byte); isBytes {
+		return []interface{}{body}
+	}
+	items := make([]interface{}, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		items = append(items, value.Index(i).Interface())
+	}
+	return items
+}
+
+/**
+ * The loop behind AsPaged, shared by the request implementations. The request points at the first page again afterwards.
+ * @param request the request, reused for every page
+ * @param retarget points the request at the url of the next page
+ * @param mappingFunction executes the request for one page
+ * @param linkExtractor extracts the next link from a page
+ * @param maxPages the maximum number of pages to request, a value <= 0 means no limit
+ * @return the pages
+ */
+func followPages(request HttpRequest, retarget func(url string), mappingFunction PageMapper, linkExtractor LinkExtractor, maxPages int) PagedList {
+	start := request.GetUrl()
+	defer retarget(start)
+	all := make(PagedList, 0)
+	visited := make(map[string]bool)
+	for {
+		current := request.GetUrl()
+		visited[current] = true
+		page := mappingFunction(request)
+		all = append(all, page)
+		if maxPages > 0 && len(all) >= maxPages {
+			return all
+		}
+		next := resolveLink(current, linkExtractor(page))
+		if next == "" || visited[next] {
+			return all
+		}
+		retarget(next)
+	}
+}
+
+func resolveLink(current string, link string) string {
+	if link == "" {
+		return ""
+	}
+	base, err := url.Parse(current)
+	if err != nil {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// serves the items a to f, two per page, linked by Link headers, or by a JSON cursor at /cursor. /gone fails
+func newPagedServer(t *testing.T) *httptest.Server {
+	items := []string{"a", "b", "c", "d", "e", "f"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		w.Header().Set(CONTENT_TYPE, string(APPLICATION_JSON))
+		if cursor := r.URL.Query().Get("cursor"); cursor != "" || r.URL.Path == "/cursor" {
+			start, _ := strconv.Atoi(cursor)
+			next := ""
+			if start+2 < len(items) {
+				next = strconv.Itoa(start + 2)
+			}
+			fmt.Fprintf(w, `{"items":["%s","%s"],"meta":{"next":%q}}`, items[start], items[start+1], next)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page < 2 {
+			page = 1
+		}
+		if page*2 < len(items) {
+			w.Header().Add(LINK, fmt.Sprintf(`</items?page=%d>; rel="next", </items?page=1>; rel="first"`, page+1))
+		}
+		fmt.Fprintf(w, `["%s","%s"]`, items[page*2-2], items[page*2-1])
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// a request which only knows its url and context, the paging code needs nothing else
+type pagingRequest struct {
+	HttpRequest
+	lock sync.Mutex
+	url  string
+	ctx  context.Context
+}
+
+func (r *pagingRequest) GetUrl() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.url
+}
+
+func (r *pagingRequest) retarget(url string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.url = url
+}
+
+func (r *pagingRequest) GetContext() context.Context {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+func (r *pagingRequest) WithContext(ctx context.Context) HttpRequest {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.ctx = ctx
+	return r
+}
+
+type pagingResponse struct {
+	HttpResponse
+	status int
+	body   interface{}
+	next   string
+}
+
+func (r *pagingResponse) IsSuccess() bool        { return r.status/100 == 2 }
+func (r *pagingResponse) GetStatus() int         { return r.status }
+func (r *pagingResponse) GetBody() interface{}   { return r.body }
+func (r *pagingResponse) GetParsingError() error { return nil }
+
+func pagingNextLink(response HttpResponse) string {
+	return response.(*pagingResponse).next
+}
+
+func joinItems(items []interface{}) string {
+	parts := make([]string, len(items))
+	for i, item := range items {
+		parts[i], _ = item.(string)
+	}
+	return strings.Join(parts, ",")
+}
+
+// three pages at /1, /2 and /3 with two items each. Pages after the first wait for the context when slow is set
+type pagingServer struct {
+	lock      sync.Mutex
+	requested []string
+	slow      bool
+}
+
+func (s *pagingServer) mapper(request HttpRequest) HttpResponse {
+	url := request.GetUrl()
+	s.lock.Lock()
+	s.requested = append(s.requested, url)
+	s.lock.Unlock()
+	if s.slow && url != "http://api/1" {
+		<-request.GetContext().Done()
+		return &pagingResponse{status: 499}
+	}
+	pages := map[string]*pagingResponse{
+		"http://api/1": {status: 200, body: []string{"a", "b"}, next: "/2"},
+		"http://api/2": {status: 200, body: []string{"c", "d"}, next: "/3"},
+		"http://api/3": {status: 200, body: []string{"e", "f"}},
+	}
+	if page, ok := pages[url]; ok {
+		return page
+	}
+	return &pagingResponse{status: 404}
+}
+
+func (s *pagingServer) requests() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]string(nil), s.requested...)
+}
+
+func TestAsPagedFollowsLinkHeaders(t *testing.T) {
+	server := newPagedServer(t)
+	client := newTestClient(t, NewDefaultConfig())
+	request := client.Get(server.URL + "/items")
+
+	pages := request.AsPaged(func(r HttpRequest) HttpResponse { return r.AsObject() }, NextLinkFromHeader)
+	if got := joinItems(pages.GetFlattenedBodies()); len(pages) != 3 || got != "a,b,c,d,e,f" {
+		t.Errorf("got %d pages: %s", len(pages), got)
+	}
+	if request.GetUrl() != server.URL+"/items" {
+		t.Errorf("request left at %s", request.GetUrl())
+	}
+	if pages := request.AsPagedWithMaxPages(func(r HttpRequest) HttpResponse { return r.AsObject() }, NextLinkFromHeader, 2); len(pages) != 2 {
+		t.Errorf("got %d pages", len(pages))
+	}
+}
+
+func TestAsPagedFollowsJsonCursors(t *testing.T) {
+	server := newPagedServer(t)
+	client := newTestClient(t, NewDefaultConfig())
+	request := client.Get(server.URL+"/cursor").QueryString("limit", 2)
+
+	pages := request.AsPaged(func(r HttpRequest) HttpResponse {
+		return r.AsJson()
+	}, JsonCursorExtractor(request, "cursor", "meta", "next"))
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages", len(pages))
+	}
+	var items []interface{}
+	for _, page := range pages {
+		value, _ := jsonBodyField(page.GetBody(), []string{"items"})
+		items = append(items, value.([]interface{})...)
+	}
+	if got := joinItems(items); got != "a,b,c,d,e,f" {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestFollowPagesRestoresTheFirstPage(t *testing.T) {
+	var server = new(pagingServer)
+	var request = &pagingRequest{url: "http://api/1"}
+
+	for round := 0; round < 2; round++ {
+		pages := followPages(request, request.retarget, server.mapper, pagingNextLink, 0)
+		if got := joinItems(pages.GetFlattenedBodies()); got != "a,b,c,d,e,f" {
+			t.Errorf("round %d: got %s", round, got)
+		}
+	}
+	if request.GetUrl() != "http://api/1" {
+		t.Errorf("request left at %s", request.GetUrl())
+	}
+}
+
+func TestFollowPagesStopsAtMaxPagesAndLoops(t *testing.T) {
+	var server = new(pagingServer)
+	var request = &pagingRequest{url: "http://api/1"}
+	if pages := followPages(request, request.retarget, server.mapper, pagingNextLink, 2); len(pages) != 2 {
+		t.Errorf("got %d pages", len(pages))
+	}
+
+	loop := func(r HttpRequest) HttpResponse {
+		return &pagingResponse{status: 200, body: []string{r.GetUrl()}, next: "/1"}
+	}
+	if pages := followPages(request, request.retarget, loop, pagingNextLink, 0); len(pages) != 1 {
+		t.Errorf("a page linking to itself was requested %d times", len(pages))
+	}
+}
@@ -0,0 +1,22 @@
+package main
+
+/**
+ * Follows the progress of an upload or download, e.g. to draw a progress bar
+ * @param field the name of the form field, empty for a download
+ * @param fileName the name of the file
+ * @param bytesWritten the bytes transferred so far
+ * @param totalBytes the size of the file, -1 when it is not known
+ */
+type ProgressMonitor func(field string, fileName string, bytesWritten int64, totalBytes int64)
+
+/**
+ * How AsFile writes the file
+ */
+type CopyOption int
+
+const (
+	// overwrite the file if it exists, otherwise AsFile fails
+	REPLACE_EXISTING CopyOption = iota
+	// write to a temporary file and move it into place once the body was read
+	ATOMIC_MOVE
+)
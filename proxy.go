@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net"
+	"net/url"
+	"strconv"
+)
+
+/**
+ * A basic HTTP proxy. The zero Proxy means no proxy
+ */
+type Proxy struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+func NewProxy(host string, port int) Proxy {
+	var proxy Proxy
+	proxy.Host = host
+	proxy.Port = port
+	return proxy
+}
+
+func NewAuthenticatedProxy(host string, port int, username string, password string) Proxy {
+	var proxy = NewProxy(host, port)
+	proxy.Username = username
+	proxy.Password = password
+	return proxy
+}
+
+/**
+ * @return true unless this is the zero Proxy
+ */
+func (p Proxy) IsSet() bool {
+	return p.Host != ""
+}
+
+/**
+ * @return the proxy as a URL for http.Transport.Proxy, nil for the zero Proxy
+ */
+func (p Proxy) URL() *url.URL {
+	if !p.IsSet() {
+		return nil
+	}
+	var proxyUrl = new(url.URL)
+	proxyUrl.Scheme = "http"
+	proxyUrl.Host = net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+	if p.Username != "" {
+		proxyUrl.User = url.UserPassword(p.Username, p.Password)
+	}
+	return proxyUrl
+}
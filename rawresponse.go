@@ -1,19 +1,265 @@
 package main
 
-import "io"
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type RawResponse interface {
 	GetStatus() int
 	GetStatusText() string
 	GetHeaders() Headers
-	GetContent() []byte // InputStream
+
+	/**
+	 * The body as it is read from the connection. It is closed automatically
+	 * after the consumer of ThenConsume returns. Once one of the buffered accessors was used
+	 * this returns a reader over the buffered bytes instead.
+	 * @return the body, never nil
+	 */
+	GetContent() io.ReadCloser
+
+	/**
+	 * Read the whole body on first use and keep it in memory
+	 * @return the body
+	 */
 	GetContentAsBytes() []byte
 	GetContentAsString() string
 	GetContentAsStringWithCharset(charset string) string
-	GetContentReader() io.ByteReader //InputStreamReader
+	GetContentReader() *bufio.Reader //InputStreamReader
 	HasContent() bool
 	GetContentType() string
+
+	/**
+	 * @return the parsed Content-Type, the zero MediaType when it is missing or invalid
+	 */
+	GetMediaType() MediaType
 	GetEncoding() string
 	GetConfig() *Config
 	ToSummary() HttpResponseSummary
 }
+
+/**
+ * A consumer of the raw response, see HttpRequest.ThenConsume
+ */
+type RawResponseConsumer func(raw RawResponse) error
+
+/**
+ * A RawResponse backed by a net/http response.
+ */
+type HttpRawResponse struct {
+	response         *http.Response
+	headers          Headers
+	config           *Config
+	elapsed          time.Duration
+	responseEncoding string
+	decompress       bool
+	body             io.ReadCloser
+	content          []byte
+	buffered         bool
+	readErr          error
+}
+
+/**
+ * @param response the net/http response, its body is read lazily
+ * @param config the current config
+ * @param elapsed the time between sending the request and receiving the response headers
+ * @return the raw response
+ */
+func NewHttpRawResponse(response *http.Response, config *Config, elapsed time.Duration) *HttpRawResponse {
+	var raw = new(HttpRawResponse)
+	raw.response = response
+	raw.headers = *NewHeaders()
+	for name, values := range response.Header {
+		for _, value := range values {
+			raw.headers.Add(name, value)
+		}
+	}
+	raw.config = config
+	raw.elapsed = elapsed
+	raw.decompress = config == nil || config.ResponseDecompressionOn
+	return raw
+}
+
+/**
+ * The encoding to expect the body to be in when the server does not name one, see HttpRequest.ResponseEncoding
+ * @param encoding a charset name
+ * @return this raw response
+ */
+func (r *HttpRawResponse) WithResponseEncoding(encoding string) *HttpRawResponse {
+	r.responseEncoding = encoding
+	return r
+}
+
+/**
+ * Turn decoding of gzip, deflate, br and zstd bodies on or off. When off the body is
+ * returned exactly as it was sent. Defaults to Config.ResponseDecompressionOn
+ * @param enabled if the body should be decompressed
+ * @return this raw response
+ */
+func (r *HttpRawResponse) WithDecompression(enabled bool) *HttpRawResponse {
+	r.decompress = enabled
+	return r
+}
+
+func (r *HttpRawResponse) decoding() bool {
+	return r.decompress && canDecompress(r.GetEncoding())
+}
+
+func (r *HttpRawResponse) GetStatus() int {
+	return r.response.StatusCode
+}
+
+/**
+ * @return the reason phrase sent by the server, or the standard one for the status code when the server left it out
+ */
+func (r *HttpRawResponse) GetStatusText() string {
+	text := strings.TrimSpace(strings.TrimPrefix(r.response.Status, strconv.Itoa(r.response.StatusCode)))
+	if text == "" {
+		return StatusCode(r.response.StatusCode).Text()
+	}
+	return text
+}
+
+/**
+ * When the body is decompressed the Content-Encoding header is left out and the Content-Length,
+ * which describes the compressed body, is replaced by the decompressed length once the body was buffered.
+ * @return the response headers
+ */
+func (r *HttpRawResponse) GetHeaders() Headers {
+	if !r.decoding() {
+		return r.headers
+	}
+	var headers = *NewHeaders()
+	for _, header := range r.headers.Headers {
+		if !isName(header, CONTENT_ENCODING) && !isName(header, CONTENT_LENGTH) {
+			headers.Headers = append(headers.Headers, header)
+		}
+	}
+	if r.buffered {
+		headers.Add(CONTENT_LENGTH, strconv.Itoa(len(r.content)))
+	}
+	return headers
+}
+
+func (r *HttpRawResponse) GetContent() io.ReadCloser {
+	if r.buffered {
+		return io.NopCloser(bytes.NewReader(r.content))
+	}
+	if r.body != nil {
+		return r.body
+	}
+	var body io.ReadCloser = http.NoBody
+	if r.response.Body != nil {
+		body = r.response.Body
+	}
+	r.body = body
+	if r.decoding() {
+		decompressed, err := decompressBody(r.GetEncoding(), body)
+		if err != nil {
+			r.body = errorBody{err: err, body: body}
+		} else {
+			r.body = decompressed
+		}
+	}
+	return r.body
+}
+
+func (r *HttpRawResponse) GetContentAsBytes() []byte {
+	if !r.buffered {
+		body := r.GetContent()
+		r.content, r.readErr = io.ReadAll(body)
+		body.Close()
+		r.buffered = true
+	}
+	return r.content
+}
+
+/**
+ * @return the error which cut reading the body short in GetContentAsBytes, if any
+ */
+func (r *HttpRawResponse) GetReadError() error {
+	return r.readErr
+}
+
+/**
+ * Decode the body with the charset from a byte order mark, the Content-Type, the request's
+ * ResponseEncoding or the config default, in that order
+ * @return the body as a string
+ */
+func (r *HttpRawResponse) GetContentAsString() string {
+	return DecodeContent(r.GetContentAsBytes(), r.GetCharset())
+}
+
+func (r *HttpRawResponse) GetContentAsStringWithCharset(charset string) string {
+	return DecodeContent(r.GetContentAsBytes(), charset)
+}
+
+/**
+ * @return the charset GetContentAsString decodes the body with
+ */
+func (r *HttpRawResponse) GetCharset() string {
+	defaultEncoding := ""
+	if r.config != nil {
+		defaultEncoding = r.config.GetDefaultResponseEncoding()
+	}
+	return detectCharset(r.GetContentAsBytes(), r.GetContentType(), r.responseEncoding, defaultEncoding)
+}
+
+func (r *HttpRawResponse) GetContentReader() *bufio.Reader {
+	return bufio.NewReader(r.GetContent())
+}
+
+func (r *HttpRawResponse) HasContent() bool {
+	if r.buffered {
+		return len(r.content) > 0
+	}
+	return r.response.Body != nil && r.response.Body != http.NoBody && r.response.ContentLength != 0
+}
+
+/**
+ * @return the Content-Type normalized by ParseContentType, or as the server sent it when it does not parse
+ */
+func (r *HttpRawResponse) GetContentType() string {
+	value := r.headers.GetFirst(CONTENT_TYPE)
+	if media, err := ParseContentType(value); err == nil {
+		return media.String()
+	}
+	return value
+}
+
+func (r *HttpRawResponse) GetMediaType() MediaType {
+	media, _ := ParseContentType(r.headers.GetFirst(CONTENT_TYPE))
+	return media
+}
+
+/**
+ * @return the Content-Encoding the server sent, also when the body is decompressed
+ */
+func (r *HttpRawResponse) GetEncoding() string {
+	return r.headers.GetFirst(CONTENT_ENCODING)
+}
+
+func (r *HttpRawResponse) GetConfig() *Config {
+	return r.config
+}
+
+func (r *HttpRawResponse) ToSummary() HttpResponseSummary {
+	return NewResponseSummary(r, r.elapsed)
+}
+
+/**
+ * Pass the raw response to the consumer and close the body once it returns, also when it panics.
+ * @param raw the raw response
+ * @param consumer the consumer
+ * @return the error of the consumer
+ */
+func consumeRawResponse(raw RawResponse, consumer RawResponseConsumer) error {
+	body := raw.GetContent()
+	defer body.Close()
+	return consumer(raw)
+}
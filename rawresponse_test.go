@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// a body which records how much was read and if it was closed
+type trackingBody struct {
+	reader io.Reader
+	read   int
+	closed bool
+}
+
+func (b *trackingBody) Read(p []byte) (int, error) {
+	n, err := b.reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *trackingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func newTrackedRawResponse(content string) (*HttpRawResponse, *trackingBody) {
+	body := &trackingBody{reader: bytes.NewReader([]byte(content))}
+	response := &http.Response{StatusCode: 200, Header: http.Header{}, Body: body, ContentLength: int64(len(content))}
+	return NewHttpRawResponse(response, NewDefaultConfig(), 0), body
+}
+
+func TestConsumeRawResponseStreamsAndCloses(t *testing.T) {
+	raw, body := newTrackedRawResponse("line one\nline two\n")
+	var first string
+	err := consumeRawResponse(raw, func(raw RawResponse) error {
+		if body.read != 0 {
+			t.Errorf("%d bytes read before the consumer asked", body.read)
+		}
+		first, _ = raw.GetContentReader().ReadString('\n')
+		return nil
+	})
+	if err != nil || first != "line one\n" {
+		t.Errorf("got %q, %v", first, err)
+	}
+	if !body.closed {
+		t.Error("body not closed after the consumer returned")
+	}
+
+	failure := errors.New("consumer failed")
+	raw, body = newTrackedRawResponse("x")
+	if err := consumeRawResponse(raw, func(RawResponse) error { return failure }); err != failure || !body.closed {
+		t.Errorf("got %v, closed %v", err, body.closed)
+	}
+
+	raw, body = newTrackedRawResponse("x")
+	func() {
+		defer func() { recover() }()
+		consumeRawResponse(raw, func(RawResponse) error { panic("boom") })
+	}()
+	if !body.closed {
+		t.Error("body not closed after the consumer panicked")
+	}
+}
+
+func TestHttpRawResponseBuffersOnDemand(t *testing.T) {
+	raw, body := newTrackedRawResponse("hello")
+	if !raw.HasContent() {
+		t.Error("HasContent() = false")
+	}
+	if got := raw.GetContentAsString(); got != "hello" || !body.closed {
+		t.Errorf("got %q, closed %v", got, body.closed)
+	}
+	again, _ := io.ReadAll(raw.GetContent())
+	if string(again) != "hello" || string(raw.GetContentAsBytes()) != "hello" {
+		t.Errorf("buffered body read again as %q", again)
+	}
+
+	empty := NewHttpRawResponse(&http.Response{StatusCode: 204, Header: http.Header{}, Body: http.NoBody}, nil, 0)
+	if empty.HasContent() || len(empty.GetContentAsBytes()) != 0 {
+		t.Error("an empty body has content")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_RETRIES   = 3
+	DEFAULT_RETRY_BACKOFF = 100 * time.Millisecond
+	MAX_RETRY_BACKOFF     = 5 * time.Second
+	// how much of the body of a response which is retried is read so its connection can be reused
+	DEFAULT_RETRY_DRAIN_LIMIT = 64 * 1024
+)
+
+/**
+ * Decide if a failed request is sent again. Only errors which report themselves as Retryable are retried.
+ * Requests with methods which are not idempotent are only retried when they cannot have reached
+ * the server, that is when the name could not be resolved or the connection could not be made.
+ * @param config the config, retries are off unless AutomaticRetries is set
+ * @param method the method of the request
+ * @param err the error of the last attempt
+ * @param attempt the number of attempts made so far, starting at 1
+ * @return true to send the request again
+ */
+func shouldRetry(config *Config, method HttpMethod, err error, attempt int) bool {
+	if !config.AutomaticRetries || attempt > config.MaxRetries || !IsRetryable(err) {
+		return false
+	}
+	if isIdempotent(method) {
+		return true
+	}
+	var dns *DNSError
+	var connect *ConnectError
+	var op *net.OpError
+	var timeout *TimeoutError
+	return errors.As(err, &dns) ||
+		(errors.As(err, &connect) && errors.As(connect.Cause, &op) && op.Op == "dial") ||
+		(errors.As(err, &timeout) && timeout.Phase == TimeoutPhaseDial)
+}
+
+func isIdempotent(method HttpMethod) bool {
+	switch strings.ToUpper(string(method)) {
+	case HttpMethodGet, HttpMethodHead, HttpMethodOptions, HttpMethodTrace, HttpMethodPut, HttpMethodDelete:
+		return true
+	}
+	return false
+}
+
+/**
+ * Release the response of an attempt which is retried. Up to DEFAULT_RETRY_DRAIN_LIMIT bytes of the body
+ * are read first, a body drained to the end lets its connection go back to the pool
+ * @param raw the response, may be nil
+ */
+func discardResponse(raw RawResponse) {
+	if raw == nil {
+		return
+	}
+	body := raw.GetContent()
+	io.CopyN(io.Discard, body, DEFAULT_RETRY_DRAIN_LIMIT)
+	body.Close()
+}
+
+/**
+ * Wait before the next attempt, doubling the backoff every attempt with some jitter
+ * @param ctx cancels the wait
+ * @param attempt the number of attempts made so far, starting at 1
+ * @return the error of ctx if it was done first
+ */
+func waitForRetry(ctx context.Context, attempt int) error {
+	backoff := retryBackoff(attempt)
+	backoff = backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/**
+ * @param attempt the number of attempts made so far, values below 1 count as 1
+ * @return DEFAULT_RETRY_BACKOFF doubled for every attempt after the first, at most MAX_RETRY_BACKOFF
+ */
+func retryBackoff(attempt int) time.Duration {
+	backoff := DEFAULT_RETRY_BACKOFF
+	for i := 1; i < attempt && backoff < MAX_RETRY_BACKOFF; i++ {
+		backoff *= 2
+	}
+	return min(backoff, MAX_RETRY_BACKOFF)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoffIsClamped(t *testing.T) {
+	cases := map[int]time.Duration{
+		math.MinInt: DEFAULT_RETRY_BACKOFF,
+		0:           DEFAULT_RETRY_BACKOFF,
+		1:           DEFAULT_RETRY_BACKOFF,
+		2:           2 * DEFAULT_RETRY_BACKOFF,
+		3:           4 * DEFAULT_RETRY_BACKOFF,
+		64:          MAX_RETRY_BACKOFF,
+		math.MaxInt: MAX_RETRY_BACKOFF,
+	}
+	for attempt, want := range cases {
+		if got := retryBackoff(attempt); got != want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestWaitForRetryHandlesAnyAttempt(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, attempt := range []int{-1, 0, 1, 63, 64, math.MaxInt} {
+		if err := waitForRetry(ctx, attempt); !errors.Is(err, context.Canceled) {
+			t.Errorf("attempt %d: got %v", attempt, err)
+		}
+	}
+	if err := waitForRetry(context.Background(), 0); err != nil {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	var config = NewDefaultConfig()
+	config.AutomaticRetries = true
+	config.MaxRetries = 2
+	dial := &ConnectError{Cause: &net.OpError{Op: "dial", Err: errors.New("refused")}}
+	read := &ConnectError{Cause: &net.OpError{Op: "read", Err: errors.New("reset")}}
+
+	if !shouldRetry(config, HttpMethodGet, read, 1) {
+		t.Error("an idempotent request was not retried")
+	}
+	if shouldRetry(config, HttpMethodPost, read, 1) {
+		t.Error("a POST which may have reached the server was retried")
+	}
+	if !shouldRetry(config, HttpMethodPost, dial, 1) {
+		t.Error("a POST which failed to connect was not retried")
+	}
+	if shouldRetry(config, HttpMethodGet, dial, 3) {
+		t.Error("retried beyond MaxRetries")
+	}
+	config.AutomaticRetries = false
+	if shouldRetry(config, HttpMethodGet, dial, 1) {
+		t.Error("retried with AutomaticRetries off")
+	}
+}
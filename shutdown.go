@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var ErrClientClosed = errors.New("fiftyrest: client is closed")
+
+const DEFAULT_SHUTDOWN_TIMEOUT = 30 * time.Second
+
+/**
+ * The shutdown half of a Client, meant to be embedded by Client implementations so they get
+ * Close and RegisterShutdownHook. Every request is wrapped in Track so Close knows what is still running.
+ */
+type ClientLifecycle struct {
+	config   *Config
+	lock     sync.Mutex
+	closing  bool
+	inFlight int
+	idle     chan struct{}
+	idleOnce sync.Once
+	closed   sync.Once
+	closeErr error
+	hookOnce sync.Once
+}
+
+/**
+ * @param config the config whose resources are released when the last client using it is closed
+ * @return the lifecycle
+ */
+func NewClientLifecycle(config *Config) *ClientLifecycle {
+	var lifecycle = new(ClientLifecycle)
+	config.acquire()
+	lifecycle.config = config
+	lifecycle.idle = make(chan struct{})
+	if config.AddShutdownHook {
+		lifecycle.RegisterShutdownHook()
+	}
+	return lifecycle
+}
+
+/**
+ * Register a request which is about to be sent
+ * @return a function to call once the request finished, or ErrClientClosed once Close was called
+ */
+func (l *ClientLifecycle) Track() (func(), error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closing {
+		return nil, ErrClientClosed
+	}
+	l.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(l.finish)
+	}, nil
+}
+
+func (l *ClientLifecycle) finish() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.inFlight--
+	if l.closing && l.inFlight == 0 {
+		l.idleOnce.Do(func() { close(l.idle) })
+	}
+}
+
+/**
+ * Stop accepting requests and wait up to Config.ShutdownTimeout for the running ones. When this is the last open
+ * client of its config, shut down the async executor, close the connection pool and the certificate reloader
+ * and run the hooks added with Config.OnShutdown. Clients sharing the config keep working until they are closed too.
+ * Calling it again returns the result of the first call.
+ * @return an error if requests were still running at the deadline or a hook failed
+ */
+func (l *ClientLifecycle) Close() error {
+	l.closed.Do(func() {
+		timeout := l.config.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = DEFAULT_SHUTDOWN_TIMEOUT
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		l.closeErr = errors.Join(l.drain(ctx), l.config.release(ctx))
+	})
+	return l.closeErr
+}
+
+func (l *ClientLifecycle) drain(ctx context.Context) error {
+	l.lock.Lock()
+	l.closing = true
+	if l.inFlight == 0 {
+		l.idleOnce.Do(func() { close(l.idle) })
+	}
+	l.lock.Unlock()
+
+	select {
+	case <-l.idle:
+		return nil
+	case <-ctx.Done():
+		l.lock.Lock()
+		running := l.inFlight
+		l.lock.Unlock()
+		return fmt.Errorf("fiftyrest: %d requests still running at shutdown: %w", running, ctx.Err())
+	}
+}
+
+/**
+ * Close the client when the process receives SIGINT or SIGTERM, then let the signal take its
+ * usual course so the process exits. Calling it again has no effect.
+ */
+func (l *ClientLifecycle) RegisterShutdownHook() {
+	l.hookOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			received := <-signals
+			signal.Stop(signals)
+			l.Close()
+			process, err := os.FindProcess(os.Getpid())
+			if err != nil || process.Signal(received) != nil {
+				os.Exit(1)
+			}
+		}()
+	})
+}
+
+// count a client created with the config
+func (c *Config) acquire() {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+	c.clients++
+}
+
+/**
+ * Count a client as closed and shut the config down when it was the last one
+ * @param ctx bounds how long to wait for running async requests
+ * @return the error of shutdown, nil while other clients still use the config
+ */
+func (c *Config) release(ctx context.Context) error {
+	clientsLock.Lock()
+	c.clients--
+	last := c.clients <= 0
+	clientsLock.Unlock()
+	if !last {
+		return nil
+	}
+	return c.shutdown(ctx)
+}
+
+/**
+ * Release what the config started lazily and run the shutdown hooks. The executor and the pool
+ * are started again should a new client use the config
+ * @param ctx bounds how long to wait for running async requests
+ * @return the errors of the executor and the hooks
+ */
+func (c *Config) shutdown(ctx context.Context) error {
+	errs := make([]error, 0)
+	asyncExecutorLock.Lock()
+	executor := c.asyncExecutor
+	c.asyncExecutor = nil
+	asyncExecutorLock.Unlock()
+	if executor != nil {
+		errs = append(errs, executor.Shutdown(ctx))
+	}
+
+	connectionPoolLock.Lock()
+	pool := c.connectionPool
+	c.connectionPool = nil
+	connectionPoolLock.Unlock()
+	if pool != nil {
+		pool.Close()
+	}
+
+	if c.Tls.ClientCertificateReloader != nil {
+		c.Tls.ClientCertificateReloader.Stop()
+	}
+	for _, hook := range c.shutdownHooks {
+		errs = append(errs, hook(ctx))
+	}
+	return errors.Join(errs...)
+}
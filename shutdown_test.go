@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientLifecycleRefusesRequestsAfterClose(t *testing.T) {
+	config := NewDefaultConfig()
+	lifecycle := NewClientLifecycle(config)
+	done, err := lifecycle.Track()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done()
+	done()
+	if err := lifecycle.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lifecycle.Track(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Track after Close: got %v", err)
+	}
+}
+
+func TestClientLifecycleCloseWaitsForRunningRequests(t *testing.T) {
+	config := NewDefaultConfig()
+	lifecycle := NewClientLifecycle(config)
+	done, err := lifecycle.Track()
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := make(chan error, 1)
+	go func() { closed <- lifecycle.Close() }()
+
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned while a request was running: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	done()
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientLifecycleCloseGivesUpAtTheTimeout(t *testing.T) {
+	config := NewDefaultConfig()
+	config.ShutdownTimeout = 10 * time.Millisecond
+	lifecycle := NewClientLifecycle(config)
+	if _, err := lifecycle.Track(); err != nil {
+		t.Fatal(err)
+	}
+	err := lifecycle.Close()
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v", err)
+	}
+	if again := lifecycle.Close(); again != err {
+		t.Errorf("a second Close returned %v", again)
+	}
+}
+
+func TestClientLifecycleReleasesTheConfig(t *testing.T) {
+	config := NewDefaultConfig()
+	pool := config.GetConnectionPool()
+	executor := config.GetAsyncExecutor()
+	failure := errors.New("flush failed")
+	hooks := 0
+	config.OnShutdown(func(ctx context.Context) error {
+		hooks++
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("the hook got no deadline")
+		}
+		return nil
+	})
+	config.OnShutdown(func(ctx context.Context) error {
+		hooks++
+		return failure
+	})
+
+	lifecycle := NewClientLifecycle(config)
+	if err := lifecycle.Close(); !errors.Is(err, failure) {
+		t.Errorf("got %v", err)
+	}
+	lifecycle.Close()
+	if hooks != 2 {
+		t.Errorf("hooks ran %d times", hooks)
+	}
+	if _, err := pool.DialContext(context.Background(), "tcp", "127.0.0.1:1"); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("the pool is still open: %v", err)
+	}
+	future := executor.Submit(context.Background(), func(ctx context.Context) (HttpResponse, error) { return nil, nil }, nil)
+	if _, err := future.Get(context.Background()); !errors.Is(err, ErrExecutorShutdown) {
+		t.Errorf("the executor still accepts work: %v", err)
+	}
+}
+
+func TestClientsSharingAConfigShutItDownWithTheLast(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	config := NewDefaultConfig()
+	hooks := 0
+	config.OnShutdown(func(ctx context.Context) error {
+		hooks++
+		return nil
+	})
+	first := newTestClient(t, config)
+	second := newTestClient(t, config)
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if hooks != 0 {
+		t.Errorf("the config was shut down with a client still open")
+	}
+	if response := second.Get(server.URL).AsString(); !response.IsSuccess() || response.GetBody() != "ok" {
+		t.Errorf("the open client failed: %v", response.GetParsingError())
+	}
+	response, err := second.Get(server.URL).AsStringAsync(context.Background()).Get(context.Background())
+	if err != nil || response.GetBody() != "ok" {
+		t.Errorf("the open client failed async: %v", err)
+	}
+
+	if err := second.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if hooks != 1 {
+		t.Errorf("hooks ran %d times", hooks)
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+/**
+ * The timeouts of a request, one per phase. A zero value means no limit, or the config value when used on a request.
+ */
+type Timeouts struct {
+	// establishing the TCP connection
+	Dial time.Duration
+	// the TLS handshake once connected
+	TLSHandshake time.Duration
+	// from writing the request to the first byte of the response, the time to first byte
+	ResponseHeader time.Duration
+	// between two reads of the response body
+	IdleRead time.Duration
+	// the whole request, from sending it until the body was read
+	Total time.Duration
+}
+
+/**
+ * @param override timeouts which win where they are set
+ * @return these timeouts with the non zero values of override applied
+ */
+func (t Timeouts) Merge(override Timeouts) Timeouts {
+	if override.Dial > 0 {
+		t.Dial = override.Dial
+	}
+	if override.TLSHandshake > 0 {
+		t.TLSHandshake = override.TLSHandshake
+	}
+	if override.ResponseHeader > 0 {
+		t.ResponseHeader = override.ResponseHeader
+	}
+	if override.IdleRead > 0 {
+		t.IdleRead = override.IdleRead
+	}
+	if override.Total > 0 {
+		t.Total = override.Total
+	}
+	return t
+}
+
+/**
+ * @param phase the phase
+ * @return the timeout of the phase, 0 when it has none
+ */
+func (t Timeouts) forPhase(phase TimeoutPhase) time.Duration {
+	switch phase {
+	case TimeoutPhaseDial:
+		return t.Dial
+	case TimeoutPhaseTLSHandshake:
+		return t.TLSHandshake
+	case TimeoutPhaseResponseHeader:
+		return t.ResponseHeader
+	case TimeoutPhaseIdleRead:
+		return t.IdleRead
+	case TimeoutPhaseTotal:
+		return t.Total
+	}
+	return 0
+}
+
+/**
+ * Enforces the Timeouts of one request by cancelling its context with a TimeoutError
+ * naming the phase which ran out. The phases are followed with an httptrace.ClientTrace,
+ * so it works with any net/http Transport shared between requests.
+ */
+type requestTimer struct {
+	ctx      context.Context
+	timeouts Timeouts
+	request  HttpRequestSummary
+	cancel   context.CancelCauseFunc
+	lock     sync.Mutex
+	phase    *time.Timer
+	total    *time.Timer
+	done     bool
+}
+
+/**
+ * Start timing a request
+ * @param parent the context of the request
+ * @param timeouts the timeouts, usually Config.GetTimeouts() merged with the request's
+ * @param request a summary of the request for the errors
+ * @return the timer and the context to send the request with
+ */
+func startRequestTimer(parent context.Context, timeouts Timeouts, request HttpRequestSummary) (*requestTimer, context.Context) {
+	ctx, cancel := context.WithCancelCause(parent)
+	var timer = new(requestTimer)
+	timer.timeouts = timeouts
+	timer.request = request
+	timer.cancel = cancel
+	if timeouts.Total > 0 {
+		timer.total = time.AfterFunc(timeouts.Total, func() {
+			timer.expire(TimeoutPhaseTotal, timeouts.Total)
+		})
+	}
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(network string, addr string) {
+			timer.startPhase(TimeoutPhaseDial, timeouts.Dial)
+		},
+		ConnectDone: func(network string, addr string, err error) {
+			timer.stopPhase()
+		},
+		TLSHandshakeStart: func() {
+			timer.startPhase(TimeoutPhaseTLSHandshake, timeouts.TLSHandshake)
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			timer.stopPhase()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			timer.startPhase(TimeoutPhaseResponseHeader, timeouts.ResponseHeader)
+		},
+		GotFirstResponseByte: func() {
+			timer.stopPhase()
+		},
+	}
+	timer.ctx = httptrace.WithClientTrace(ctx, trace)
+	return timer, timer.ctx
+}
+
+/**
+ * @param err an error of the transport or of reading the body
+ * @return the TimeoutError when a timeout cancelled the request, a CancelledError when the caller did, else err
+ */
+func (t *requestTimer) err(err error) error {
+	return wrapContextError(t.ctx, err, t.request)
+}
+
+func (t *requestTimer) startPhase(phase TimeoutPhase, timeout time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.phase != nil {
+		t.phase.Stop()
+		t.phase = nil
+	}
+	if timeout > 0 && !t.done {
+		t.phase = time.AfterFunc(timeout, func() {
+			t.expire(phase, timeout)
+		})
+	}
+}
+
+func (t *requestTimer) stopPhase() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.phase != nil {
+		t.phase.Stop()
+		t.phase = nil
+	}
+}
+
+func (t *requestTimer) expire(phase TimeoutPhase, timeout time.Duration) {
+	t.cancel(&TimeoutError{Phase: phase, Timeout: timeout, Request: t.request, Cause: context.DeadlineExceeded})
+}
+
+/**
+ * Keep timing while the body is read: every read restarts the idle read timeout and
+ * closing the body stops the timer.
+ * @param body the response body
+ * @return the body to hand to the caller
+ */
+func (t *requestTimer) wrapBody(body io.ReadCloser) io.ReadCloser {
+	t.startPhase(TimeoutPhaseIdleRead, t.timeouts.IdleRead)
+	return &timedBody{ReadCloser: body, timer: t}
+}
+
+/**
+ * Stop the timer and release the context. Called when the body was closed or the request failed.
+ */
+func (t *requestTimer) finish() {
+	t.lock.Lock()
+	t.done = true
+	if t.phase != nil {
+		t.phase.Stop()
+	}
+	if t.total != nil {
+		t.total.Stop()
+	}
+	t.lock.Unlock()
+	t.cancel(nil)
+}
+
+type timedBody struct {
+	io.ReadCloser
+	timer *requestTimer
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == nil {
+		b.timer.startPhase(TimeoutPhaseIdleRead, b.timer.timeouts.IdleRead)
+		return n, nil
+	}
+	b.timer.stopPhase()
+	if err == io.EOF {
+		return n, err
+	}
+	return n, b.timer.err(err)
+}
+
+func (b *timedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.timer.finish()
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeoutsMerge(t *testing.T) {
+	base := Timeouts{Dial: time.Second, IdleRead: 2 * time.Second, Total: 3 * time.Second}
+	merged := base.Merge(Timeouts{IdleRead: time.Minute, ResponseHeader: time.Millisecond})
+	expected := Timeouts{Dial: time.Second, IdleRead: time.Minute, ResponseHeader: time.Millisecond, Total: 3 * time.Second}
+	if merged != expected {
+		t.Errorf("got %+v", merged)
+	}
+
+	config := NewDefaultConfig()
+	config.ConnectionTimeout = 500
+	config.Timeouts.TLSHandshake = time.Second
+	if timeouts := config.GetTimeouts(); timeouts.Dial != 500*time.Millisecond || timeouts.IdleRead != DEFAULT_SOCKET_TIMEOUT*time.Millisecond || timeouts.TLSHandshake != time.Second {
+		t.Errorf("config: %+v", timeouts)
+	}
+	config.Timeouts.Dial = time.Minute
+	if timeouts := config.GetTimeouts(); timeouts.Dial != time.Minute {
+		t.Errorf("Timeouts.Dial did not win over ConnectionTimeout: %+v", timeouts)
+	}
+}
+
+// stallingServer sends the headers and a first chunk of the body, then stalls until the test ends
+func stallingServer(t *testing.T) *httptest.Server {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "first")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+	return server
+}
+
+// timedGet sends a GET with the timeouts and reads the whole body
+func timedGet(t *testing.T, url string, timeouts Timeouts) error {
+	timer, ctx := startRequestTimer(context.Background(), timeouts, nil)
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		err = timer.err(err)
+		timer.finish()
+		return err
+	}
+	body := timer.wrapBody(response.Body)
+	defer body.Close()
+	_, err = io.ReadAll(body)
+	return err
+}
+
+func TestRequestTimerIdleRead(t *testing.T) {
+	server := stallingServer(t)
+	err := timedGet(t, server.URL, Timeouts{IdleRead: 50 * time.Millisecond})
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Phase != TimeoutPhaseIdleRead || timeout.Timeout != 50*time.Millisecond {
+		t.Errorf("got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("%v does not unwrap to DeadlineExceeded", err)
+	}
+}
+
+func TestRequestTimerTotal(t *testing.T) {
+	server := stallingServer(t)
+	err := timedGet(t, server.URL, Timeouts{Total: 50 * time.Millisecond, IdleRead: time.Minute})
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || timeout.Phase != TimeoutPhaseTotal {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestRequestTimerStopsOnceFinished(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "done")
+	}))
+	defer server.Close()
+	if err := timedGet(t, server.URL, Timeouts{ResponseHeader: 20 * time.Millisecond, IdleRead: 20 * time.Millisecond, Total: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+
+	timer, ctx := startRequestTimer(context.Background(), Timeouts{Total: 10 * time.Millisecond}, nil)
+	timer.finish()
+	time.Sleep(30 * time.Millisecond)
+	var timeout *TimeoutError
+	if errors.As(context.Cause(ctx), &timeout) {
+		t.Errorf("the total timeout fired after finish: %v", timeout)
+	}
+}
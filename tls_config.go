@@ -0,0 +1,244 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var ErrPinMismatch = errors.New("fiftyrest: no certificate of the chain matches a pinned public key")
+
+/**
+ * Decides if a server may be talked to under the name that was dialed. Replaces the default
+ * check of the certificate's DNS names; the chain is still verified against the root CAs when VerifySsl is on.
+ * @param hostname the server name the client dialed, empty when an IP address was dialed
+ * @param state the state of the handshake, including the peer certificates
+ * @return an error to abort the handshake
+ */
+type HostnameVerifier func(hostname string, state tls.ConnectionState) error
+
+/**
+ * The TLS settings of a Config
+ */
+type TlsConfig struct {
+	// PEM files with root CAs to trust instead of the system pool
+	CaBundleFiles []string
+	// PEM encoded root CAs to trust instead of the system pool, added to those of CaBundleFiles
+	CaBundlePEM []byte
+
+	// PEM files of the client certificate chain and its private key for mutual TLS
+	ClientCertificateFile string
+	ClientKeyFile         string
+	// a PKCS#12 (.p12/.pfx) file with the client certificate and key, used instead of the PEM files when set
+	ClientPkcs12File     string
+	ClientPkcs12Password string
+	// supplies a client certificate which is rotated while the client runs, used instead of the files when set
+	ClientCertificateReloader *CertificateReloader
+
+	// tls.VersionTLS12 etc, 0 leaves the crypto/tls default
+	MinVersion uint16
+	MaxVersion uint16
+	// names of the allowed TLS 1.0-1.2 cipher suites as listed by tls.CipherSuites(), e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+	// empty allows the crypto/tls defaults. TLS 1.3 suites are not configurable
+	CipherSuites []string
+
+	// base64 encoded SHA-256 hashes of the SubjectPublicKeyInfo of trusted keys, optionally prefixed with "sha256/".
+	// When set, the handshake fails unless a certificate of the chain has one of these keys
+	PinnedPublicKeys []string
+
+	HostnameVerifier HostnameVerifier
+}
+
+/**
+ * Build the crypto/tls configuration for the client
+ * @param verifySsl if the server certificate chain is verified, see Config.VerifySsl
+ * @return the tls config
+ */
+func (t *TlsConfig) Build(verifySsl bool) (*tls.Config, error) {
+	var config = new(tls.Config)
+	config.MinVersion = t.MinVersion
+	config.MaxVersion = t.MaxVersion
+
+	roots, err := t.loadRootCAs()
+	if err != nil {
+		return nil, err
+	}
+	config.RootCAs = roots
+
+	if t.ClientCertificateReloader != nil {
+		config.GetClientCertificate = t.ClientCertificateReloader.GetClientCertificate
+	} else {
+		certificate, err := t.loadClientCertificate()
+		if err != nil {
+			return nil, err
+		}
+		if certificate != nil {
+			config.Certificates = []tls.Certificate{*certificate}
+		}
+	}
+
+	suites, err := cipherSuiteIds(t.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+	config.CipherSuites = suites
+
+	pins, err := parsePins(t.PinnedPublicKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	// the hostname hook replaces the built in verification, which can only be turned off as a whole
+	config.InsecureSkipVerify = !verifySsl || t.HostnameVerifier != nil
+	verifier := t.HostnameVerifier
+	config.VerifyConnection = func(state tls.ConnectionState) error {
+		chains := state.VerifiedChains
+		if verifySsl && verifier != nil {
+			verified, err := verifyChain(state, roots)
+			if err != nil {
+				return err
+			}
+			chains = verified
+		}
+		if verifier != nil {
+			if err := verifier(state.ServerName, state); err != nil {
+				return err
+			}
+		}
+		return checkPins(pins, chains, state.PeerCertificates)
+	}
+	return config, nil
+}
+
+func (t *TlsConfig) loadRootCAs() (*x509.CertPool, error) {
+	if len(t.CaBundleFiles) == 0 && len(t.CaBundlePEM) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, file := range t.CaBundleFiles {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("fiftyrest: reading CA bundle: %w", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("fiftyrest: no certificates found in CA bundle %s", file)
+		}
+	}
+	if len(t.CaBundlePEM) > 0 && !pool.AppendCertsFromPEM(t.CaBundlePEM) {
+		return nil, errors.New("fiftyrest: no certificates found in CaBundlePEM")
+	}
+	return pool, nil
+}
+
+func (t *TlsConfig) loadClientCertificate() (*tls.Certificate, error) {
+	if t.ClientPkcs12File != "" {
+		return loadPkcs12(t.ClientPkcs12File, t.ClientPkcs12Password)
+	}
+	if t.ClientCertificateFile == "" && t.ClientKeyFile == "" {
+		return nil, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(t.ClientCertificateFile, t.ClientKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("fiftyrest: loading client certificate: %w", err)
+	}
+	return &certificate, nil
+}
+
+func loadPkcs12(file string, password string) (*tls.Certificate, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("fiftyrest: reading PKCS#12 file: %w", err)
+	}
+	key, leaf, chain, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("fiftyrest: decoding PKCS#12 file %s: %w", file, err)
+	}
+	var certificate tls.Certificate
+	certificate.PrivateKey = key
+	certificate.Leaf = leaf
+	certificate.Certificate = [][]byte{leaf.Raw}
+	for _, cert := range chain {
+		certificate.Certificate = append(certificate.Certificate, cert.Raw)
+	}
+	return &certificate, nil
+}
+
+func cipherSuiteIds(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("fiftyrest: unknown cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parsePins(pins []string) (map[string]bool, error) {
+	parsed := make(map[string]bool)
+	for _, pin := range pins {
+		pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+		hash, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(hash) != sha256.Size {
+			return nil, fmt.Errorf("fiftyrest: invalid public key pin %q", pin)
+		}
+		parsed[string(hash)] = true
+	}
+	return parsed, nil
+}
+
+/**
+ * @param certificate a certificate
+ * @return the pin of the certificate's public key in the format of TlsConfig.PinnedPublicKeys
+ */
+func PublicKeyPin(certificate *x509.Certificate) string {
+	hash := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func verifyChain(state tls.ConnectionState, roots *x509.CertPool) ([][]*x509.Certificate, error) {
+	if len(state.PeerCertificates) == 0 {
+		return nil, errors.New("fiftyrest: server presented no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	return state.PeerCertificates[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+}
+
+func checkPins(pins map[string]bool, chains [][]*x509.Certificate, peers []*x509.Certificate) error {
+	if len(pins) == 0 {
+		return nil
+	}
+	// only trust certificates of verified chains, otherwise a pinned certificate could simply be appended to any chain
+	candidates := peers
+	if len(chains) > 0 {
+		candidates = make([]*x509.Certificate, 0)
+		for _, chain := range chains {
+			candidates = append(candidates, chain...)
+		}
+	}
+	for _, cert := range candidates {
+		hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		if pins[string(hash[:])] {
+			return nil
+		}
+	}
+	return ErrPinMismatch
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTLSServer(t *testing.T) *httptest.Server {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func serverCaPEM(server *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+}
+
+func getWithTls(t *testing.T, tlsConfig TlsConfig, verifySsl bool, url string) error {
+	config, err := tlsConfig.Build(verifySsl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	response, err := client.Get(url)
+	if err == nil {
+		response.Body.Close()
+	}
+	return err
+}
+
+func TestTlsConfigTrustsTheCaBundle(t *testing.T) {
+	server := newTLSServer(t)
+	if err := getWithTls(t, TlsConfig{}, true, server.URL); err == nil {
+		t.Error("an unknown CA was trusted")
+	}
+	if err := getWithTls(t, TlsConfig{CaBundlePEM: serverCaPEM(server)}, true, server.URL); err != nil {
+		t.Error(err)
+	}
+	if err := getWithTls(t, TlsConfig{}, false, server.URL); err != nil {
+		t.Errorf("VerifySsl off: %v", err)
+	}
+	if _, err := (&TlsConfig{CaBundlePEM: []byte("not pem")}).Build(true); err == nil {
+		t.Error("a bundle without certificates was accepted")
+	}
+}
+
+func TestTlsConfigPinsPublicKeys(t *testing.T) {
+	server := newTLSServer(t)
+	pin := PublicKeyPin(server.Certificate())
+	if err := getWithTls(t, TlsConfig{CaBundlePEM: serverCaPEM(server), PinnedPublicKeys: []string{pin}}, true, server.URL); err != nil {
+		t.Error(err)
+	}
+
+	other := sha256.Sum256([]byte("another key"))
+	err := getWithTls(t, TlsConfig{CaBundlePEM: serverCaPEM(server), PinnedPublicKeys: []string{base64.StdEncoding.EncodeToString(other[:])}}, true, server.URL)
+	if !errors.Is(err, ErrPinMismatch) || !isTLSFailure(err) {
+		t.Errorf("got %v", err)
+	}
+	if _, err := (&TlsConfig{PinnedPublicKeys: []string{"sha256/short"}}).Build(true); err == nil {
+		t.Error("an invalid pin was accepted")
+	}
+}
+
+func TestTlsConfigHostnameVerifier(t *testing.T) {
+	server := newTLSServer(t)
+	var verified string
+	tlsConfig := TlsConfig{CaBundlePEM: serverCaPEM(server), HostnameVerifier: func(hostname string, state tls.ConnectionState) error {
+		verified = hostname
+		if len(state.PeerCertificates) == 0 {
+			return errors.New("no certificate")
+		}
+		return nil
+	}}
+	// the test certificate is issued for example.com, the verifier decides instead of the DNS names
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	if err := getWithTls(t, tlsConfig, true, url); err != nil {
+		t.Fatal(err)
+	}
+	if verified != "localhost" {
+		t.Errorf("verifier saw %q", verified)
+	}
+
+	refused := errors.New("refused by verifier")
+	tlsConfig.HostnameVerifier = func(string, tls.ConnectionState) error { return refused }
+	if err := getWithTls(t, tlsConfig, true, url); !errors.Is(err, refused) {
+		t.Errorf("got %v", err)
+	}
+	tlsConfig.CaBundlePEM = nil
+	tlsConfig.HostnameVerifier = func(string, tls.ConnectionState) error { return nil }
+	if err := getWithTls(t, tlsConfig, true, url); err == nil {
+		t.Error("the verifier skipped chain verification")
+	}
+}
+
+func TestTlsConfigCipherSuites(t *testing.T) {
+	config, err := (&TlsConfig{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}}).Build(true)
+	if err != nil || len(config.CipherSuites) != 1 || config.CipherSuites[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("got %v, %v", config, err)
+	}
+	if _, err := (&TlsConfig{CipherSuites: []string{"TLS_NOT_A_SUITE"}}).Build(true); err == nil {
+		t.Error("an unknown suite was accepted")
+	}
+}
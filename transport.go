@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// the context key under which a request carries the proxy set with HttpRequest.Proxy
+type proxyContextKey struct{}
+
+/**
+ * Build the net/http transport of a client from the config: TLS from BuildTlsConfig, connections
+ * from GetConnectionPool and the proxy of each request, or of the environment when UseSystemProperties is set.
+ * DisableCompression is set because this package negotiates Accept-Encoding and decodes bodies itself,
+ * see negotiateAcceptEncoding and HttpRawResponse.WithDecompression. Left on, net/http would add
+ * Accept-Encoding: gzip on its own and decode gzip before DecompressResponse(false) could keep the body as sent.
+ * @param config the config
+ * @return the transport, or the error of BuildTlsConfig
+ */
+func newTransport(config *Config) (*http.Transport, error) {
+	tlsConfig, err := config.BuildTlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	var transport = new(http.Transport)
+	transport.TLSClientConfig = tlsConfig
+	transport.ForceAttemptHTTP2 = true
+	transport.DisableCompression = true
+	transport.Proxy = func(request *http.Request) (*url.URL, error) {
+		if proxy, ok := request.Context().Value(proxyContextKey{}).(*url.URL); ok {
+			return proxy, nil
+		}
+		if config.UseSystemProperties {
+			return http.ProxyFromEnvironment(request)
+		}
+		return nil, nil
+	}
+	config.GetConnectionPool().ConfigureTransport(transport)
+	return transport, nil
+}
+
+/**
+ * @param ctx the context of the request
+ * @param proxy the proxy of the request, the zero Proxy leaves ctx as it is
+ * @return the context telling the transport of newTransport to use proxy
+ */
+func withProxy(ctx context.Context, proxy Proxy) context.Context {
+	if !proxy.IsSet() {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyContextKey{}, proxy.URL())
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestTransportLeavesCompressionToThePackage(t *testing.T) {
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte("hello"))
+	writer.Close()
+
+	var acceptEncoding []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Values(ACCEPT_ENCODING)
+		w.Header().Set(CONTENT_ENCODING, ENCODING_GZIP)
+		w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	var config = NewDefaultConfig()
+	defer config.shutdown(context.Background())
+	transport, err := newTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	body, _ := io.ReadAll(response.Body)
+
+	if len(acceptEncoding) != 0 {
+		t.Errorf("the transport sent Accept-Encoding %v on its own", acceptEncoding)
+	}
+	if !bytes.Equal(body, compressed.Bytes()) || response.Header.Get(CONTENT_ENCODING) != ENCODING_GZIP {
+		t.Errorf("the transport decoded the body: %q", body)
+	}
+}
+
+func TestTransportUsesTheProxyOfTheRequest(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+	address, _ := url.Parse(proxy.URL)
+	port, _ := strconv.Atoi(address.Port())
+
+	var config = NewDefaultConfig()
+	config.UseSystemProperties = false
+	defer config.shutdown(context.Background())
+	transport, err := newTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _ := http.NewRequestWithContext(withProxy(context.Background(), NewProxy(address.Hostname(), port)), http.MethodGet, "http://api.invalid/users", nil)
+	response, err := transport.RoundTrip(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if proxied != "http://api.invalid/users" {
+		t.Errorf("proxy saw %q", proxied)
+	}
+}
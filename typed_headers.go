@@ -0,0 +1,677 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/**
+ * @return the Content-Length, false when it is missing or not a valid length
+ */
+func (h *Headers) GetContentLength() (int64, bool) {
+	length := parseContentLength(h.GetFirst(CONTENT_LENGTH))
+	return length, length >= 0
+}
+
+/**
+ * @param length the length of the body in bytes
+ */
+func (h *Headers) SetContentLength(length int64) {
+	h.Replace(CONTENT_LENGTH, strconv.FormatInt(length, 10))
+}
+
+/**
+ * Read a header holding an HTTP date like Date, Expires, Last-Modified or If-Modified-Since.
+ * The IMF-fixdate format and the obsolete RFC 850 and asctime formats are understood
+ * @param name the name of the header
+ * @return the time, false when the header is missing or not a date
+ */
+func (h *Headers) GetTime(name string) (time.Time, bool) {
+	value := strings.TrimSpace(h.GetFirst(name))
+	if value == "" {
+		return time.Time{}, false
+	}
+	parsed, err := http.ParseTime(value)
+	return parsed, err == nil
+}
+
+/**
+ * Set a header holding an HTTP date, formatted as IMF-fixdate in GMT
+ * @param name the name of the header
+ * @param value the time
+ */
+func (h *Headers) SetTime(name string, value time.Time) {
+	h.Replace(name, value.UTC().Format(http.TimeFormat))
+}
+
+/**
+ * A Cache-Control directive, Value is empty for directives without an argument
+ */
+type CacheDirective struct {
+	Name  string
+	Value string
+}
+
+/**
+ * The parsed directives of a Cache-Control header, in the order they were sent
+ */
+type CacheControl []CacheDirective
+
+/**
+ * @param value a Cache-Control header value, e.g. <pre>public, max-age=3600</pre>
+ * @return the directives with lower case names
+ */
+func ParseCacheControl(value string) CacheControl {
+	control := make(CacheControl, 0)
+	for _, part := range splitQuoted(value, ',') {
+		name, argument, _ := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "" {
+			control = append(control, CacheDirective{Name: name, Value: unquote(strings.TrimSpace(argument))})
+		}
+	}
+	return control
+}
+
+/**
+ * @param name a directive like no-store
+ * @return the argument of the directive and true if it is present
+ */
+func (c CacheControl) Get(name string) (string, bool) {
+	for _, directive := range c {
+		if strings.EqualFold(directive.Name, name) {
+			return directive.Value, true
+		}
+	}
+	return "", false
+}
+
+/**
+ * @param name a directive like no-store
+ * @return true if the directive is present
+ */
+func (c CacheControl) Has(name string) bool {
+	_, ok := c.Get(name)
+	return ok
+}
+
+/**
+ * @return the max-age directive, false when it is missing or invalid
+ */
+func (c CacheControl) MaxAge() (time.Duration, bool) {
+	return c.seconds("max-age")
+}
+
+/**
+ * @return the s-maxage directive, false when it is missing or invalid
+ */
+func (c CacheControl) SMaxAge() (time.Duration, bool) {
+	return c.seconds("s-maxage")
+}
+
+func (c CacheControl) seconds(name string) (time.Duration, bool) {
+	value, ok := c.Get(name)
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
+
+func (c CacheControl) NoCache() bool {
+	return c.Has("no-cache")
+}
+
+func (c CacheControl) NoStore() bool {
+	return c.Has("no-store")
+}
+
+func (c CacheControl) Private() bool {
+	return c.Has("private")
+}
+
+func (c CacheControl) Public() bool {
+	return c.Has("public")
+}
+
+func (c CacheControl) MustRevalidate() bool {
+	return c.Has("must-revalidate")
+}
+
+/**
+ * @return the header value, arguments quoted where needed
+ */
+func (c CacheControl) String() string {
+	parts := make([]string, 0, len(c))
+	for _, directive := range c {
+		if directive.Value == "" {
+			parts = append(parts, directive.Name)
+		} else {
+			parts = append(parts, directive.Name+"="+quoteIfNeeded(directive.Value))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+/**
+ * @return the directives of all Cache-Control headers
+ */
+func (h *Headers) GetCacheControl() CacheControl {
+	return ParseCacheControl(strings.Join(h.Get(CACHE_CONTROL), ", "))
+}
+
+/**
+ * @param control the directives
+ */
+func (h *Headers) SetCacheControl(control CacheControl) {
+	h.Replace(CACHE_CONTROL, control.String())
+}
+
+/**
+ * An entity tag of ETag, If-Match or If-None-Match. The wildcard of If-Match is an EntityTag with Value "*"
+ */
+type EntityTag struct {
+	// the opaque tag without quotes
+	Value string
+	Weak  bool
+}
+
+/**
+ * @param value an entity tag like <pre>"xyz"</pre> or <pre>W/"xyz"</pre>
+ * @return the entity tag, false when value is not one
+ */
+func ParseEntityTag(value string) (EntityTag, bool) {
+	var tag EntityTag
+	value = strings.TrimSpace(value)
+	if value == "*" {
+		tag.Value = "*"
+		return tag, true
+	}
+	if strings.HasPrefix(value, "W/") {
+		tag.Weak = true
+		value = value[2:]
+	}
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' || strings.ContainsRune(value[1:len(value)-1], '"') {
+		return EntityTag{}, false
+	}
+	tag.Value = value[1 : len(value)-1]
+	return tag, true
+}
+
+/**
+ * @return true for the wildcard of If-Match and If-None-Match
+ */
+func (e EntityTag) IsAny() bool {
+	return e.Value == "*" && !e.Weak
+}
+
+/**
+ * The strong comparison of RFC 9110, used by If-Match and Range requests
+ * @param other another tag
+ * @return true if both tags are strong and have the same value
+ */
+func (e EntityTag) StrongMatch(other EntityTag) bool {
+	return !e.Weak && !other.Weak && e.Value == other.Value
+}
+
+/**
+ * The weak comparison of RFC 9110, used by If-None-Match
+ * @param other another tag
+ * @return true if the tags have the same value
+ */
+func (e EntityTag) WeakMatch(other EntityTag) bool {
+	return e.Value == other.Value
+}
+
+func (e EntityTag) String() string {
+	if e.IsAny() {
+		return "*"
+	}
+	if e.Weak {
+		return `W/"` + e.Value + `"`
+	}
+	return `"` + e.Value + `"`
+}
+
+/**
+ * @return the ETag, false when it is missing or malformed
+ */
+func (h *Headers) GetETag() (EntityTag, bool) {
+	tag, ok := ParseEntityTag(h.GetFirst(ETAG))
+	return tag, ok && !tag.IsAny()
+}
+
+func (h *Headers) SetETag(tag EntityTag) {
+	h.Replace(ETAG, tag.String())
+}
+
+/**
+ * Read a list of entity tags like If-Match or If-None-Match. Malformed tags are skipped
+ * @param name the name of the header
+ * @return the tags of all headers with that name
+ */
+func (h *Headers) GetEntityTags(name string) []EntityTag {
+	tags := make([]EntityTag, 0)
+	for _, part := range splitQuoted(strings.Join(h.Get(name), ","), ',') {
+		if tag, ok := ParseEntityTag(part); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+/**
+ * @param name the name of the header, e.g. IF_MATCH
+ * @param tags the tags, an EntityTag with Value "*" for any
+ */
+func (h *Headers) SetEntityTags(name string, tags ...EntityTag) {
+	values := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		values = append(values, tag.String())
+	}
+	h.Replace(name, strings.Join(values, ", "))
+}
+
+/**
+ * @return the tags of If-Match
+ */
+func (h *Headers) GetIfMatch() []EntityTag {
+	return h.GetEntityTags(IF_MATCH)
+}
+
+func (h *Headers) SetIfMatch(tags ...EntityTag) {
+	h.SetEntityTags(IF_MATCH, tags...)
+}
+
+/**
+ * A range of bytes, both ends inclusive. End is -1 for an open range like <pre>500-</pre>
+ * and Start is -1 for a suffix range like <pre>-500</pre>, which asks for the last End bytes.
+ */
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+func (r ByteRange) String() string {
+	switch {
+	case r.Start < 0:
+		return "-" + strconv.FormatInt(r.End, 10)
+	case r.End < 0:
+		return strconv.FormatInt(r.Start, 10) + "-"
+	}
+	return strconv.FormatInt(r.Start, 10) + "-" + strconv.FormatInt(r.End, 10)
+}
+
+/**
+ * @param value a Range header value like <pre>bytes=0-499, -500</pre>
+ * @return the ranges, false when the unit is not bytes or a range is malformed
+ */
+func ParseRange(value string) ([]ByteRange, bool) {
+	unit, list, ok := strings.Cut(strings.TrimSpace(value), "=")
+	if !ok || !strings.EqualFold(strings.TrimSpace(unit), "bytes") {
+		return nil, false
+	}
+	ranges := make([]ByteRange, 0)
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, false
+		}
+		byteRange := ByteRange{Start: -1, End: -1}
+		var err error
+		if first != "" {
+			if byteRange.Start, err = strconv.ParseInt(first, 10, 64); err != nil || byteRange.Start < 0 {
+				return nil, false
+			}
+		}
+		if last != "" {
+			if byteRange.End, err = strconv.ParseInt(last, 10, 64); err != nil || byteRange.End < 0 {
+				return nil, false
+			}
+		}
+		if (first == "" && last == "") || (first != "" && last != "" && byteRange.End < byteRange.Start) {
+			return nil, false
+		}
+		ranges = append(ranges, byteRange)
+	}
+	return ranges, len(ranges) > 0
+}
+
+/**
+ * @return the byte ranges of the Range header, false when it is missing or malformed
+ */
+func (h *Headers) GetRange() ([]ByteRange, bool) {
+	return ParseRange(h.GetFirst(RANGE))
+}
+
+/**
+ * @param ranges the byte ranges to ask for
+ */
+func (h *Headers) SetRange(ranges ...ByteRange) {
+	values := make([]string, 0, len(ranges))
+	for _, byteRange := range ranges {
+		values = append(values, byteRange.String())
+	}
+	h.Replace(RANGE, "bytes="+strings.Join(values, ", "))
+}
+
+/**
+ * The Content-Range of a partial response. For a 416 response Start and End are -1 and only Size is set
+ */
+type ContentRange struct {
+	Unit  string
+	Start int64
+	End   int64
+	// the length of the whole representation, -1 when the server did not know it
+	Size int64
+}
+
+/**
+ * @return true for the form of a 416 response, which only sends the size
+ */
+func (r ContentRange) IsUnsatisfied() bool {
+	return r.Start < 0
+}
+
+func (r ContentRange) String() string {
+	size := "*"
+	if r.Size >= 0 {
+		size = strconv.FormatInt(r.Size, 10)
+	}
+	if r.IsUnsatisfied() {
+		return r.Unit + " */" + size
+	}
+	return fmt.Sprintf("%s %d-%d/%s", r.Unit, r.Start, r.End, size)
+}
+
+/**
+ * @param value a Content-Range header value like <pre>bytes 0-499/1234</pre>
+ * @return the range, false when it is malformed
+ */
+func ParseContentRange(value string) (ContentRange, bool) {
+	var contentRange = ContentRange{Start: -1, End: -1, Size: -1}
+	unit, rest, ok := strings.Cut(strings.TrimSpace(value), " ")
+	if !ok || unit == "" {
+		return ContentRange{}, false
+	}
+	contentRange.Unit = unit
+	span, size, ok := strings.Cut(strings.TrimSpace(rest), "/")
+	if !ok {
+		return ContentRange{}, false
+	}
+	var err error
+	if size != "*" {
+		if contentRange.Size, err = strconv.ParseInt(size, 10, 64); err != nil || contentRange.Size < 0 {
+			return ContentRange{}, false
+		}
+	}
+	if span == "*" {
+		return contentRange, contentRange.Size >= 0
+	}
+	first, last, ok := strings.Cut(span, "-")
+	if !ok {
+		return ContentRange{}, false
+	}
+	if contentRange.Start, err = strconv.ParseInt(first, 10, 64); err != nil || contentRange.Start < 0 {
+		return ContentRange{}, false
+	}
+	if contentRange.End, err = strconv.ParseInt(last, 10, 64); err != nil || contentRange.End < contentRange.Start {
+		return ContentRange{}, false
+	}
+	if contentRange.Size >= 0 && contentRange.End >= contentRange.Size {
+		return ContentRange{}, false
+	}
+	return contentRange, true
+}
+
+/**
+ * @return the Content-Range, false when it is missing or malformed
+ */
+func (h *Headers) GetContentRange() (ContentRange, bool) {
+	return ParseContentRange(h.GetFirst(CONTENT_RANGE))
+}
+
+func (h *Headers) SetContentRange(contentRange ContentRange) {
+	h.Replace(CONTENT_RANGE, contentRange.String())
+}
+
+/**
+ * Read Retry-After, which is either a number of seconds or an HTTP date
+ * @param now the time the response was received, the delay of a date is counted from it
+ * @return the delay, never negative, false when the header is missing or malformed
+ */
+func (h *Headers) GetRetryAfter(now time.Time) (time.Duration, bool) {
+	value := strings.TrimSpace(h.GetFirst(RETRY_AFTER))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if delay := at.Sub(now); delay > 0 {
+		return delay, true
+	}
+	return 0, true
+}
+
+/**
+ * @param delay the delay, sent in whole seconds
+ * @return an error if delay is negative, Retry-After cannot express it
+ */
+func (h *Headers) SetRetryAfter(delay time.Duration) error {
+	if delay < 0 {
+		return fmt.Errorf("fiftyrest: Retry-After delay %v is negative", delay)
+	}
+	h.Replace(RETRY_AFTER, strconv.FormatInt(int64(delay.Round(time.Second)/time.Second), 10))
+	return nil
+}
+
+/**
+ * An authentication challenge of WWW-Authenticate or Proxy-Authenticate, e.g.
+ * <pre>Bearer realm="api", error="invalid_token"</pre>
+ */
+type Challenge struct {
+	Scheme string
+	// the token68 form some schemes use instead of parameters, e.g. for Negotiate
+	Token68 string
+	// the parameters with lower case names
+	Params map[string]string
+}
+
+/**
+ * @return the realm parameter
+ */
+func (c Challenge) Realm() string {
+	return c.Params["realm"]
+}
+
+func (c Challenge) String() string {
+	if c.Token68 != "" {
+		return c.Scheme + " " + c.Token68
+	}
+	if len(c.Params) == 0 {
+		return c.Scheme
+	}
+	names := make([]string, 0, len(c.Params))
+	for name := range c.Params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := make([]string, 0, len(names))
+	for _, name := range names {
+		params = append(params, name+"="+quoteString(c.Params[name]))
+	}
+	return c.Scheme + " " + strings.Join(params, ", ")
+}
+
+/**
+ * Parse a WWW-Authenticate or Proxy-Authenticate value, which may hold several challenges
+ * @param value the header value
+ * @return the challenges in order, parts which do not parse are skipped
+ */
+func ParseChallenges(value string) []Challenge {
+	challenges := make([]Challenge, 0)
+	var current *Challenge
+	for _, part := range splitQuoted(value, ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if current != nil && isAuthParam(part) {
+			name, argument, _ := strings.Cut(part, "=")
+			current.Params[strings.ToLower(strings.TrimSpace(name))] = unquote(strings.TrimSpace(argument))
+			continue
+		}
+		// a new challenge: the scheme, optionally followed by a token68 or its first parameter
+		scheme, rest, _ := strings.Cut(part, " ")
+		challenges = append(challenges, Challenge{Scheme: scheme, Params: make(map[string]string)})
+		current = &challenges[len(challenges)-1]
+		rest = strings.TrimSpace(rest)
+		if isAuthParam(rest) {
+			name, argument, _ := strings.Cut(rest, "=")
+			current.Params[strings.ToLower(strings.TrimSpace(name))] = unquote(strings.TrimSpace(argument))
+		} else {
+			current.Token68 = rest
+		}
+	}
+	return challenges
+}
+
+func isAuthParam(part string) bool {
+	name, argument, ok := strings.Cut(part, "=")
+	name = strings.TrimSpace(name)
+	// a token68 may end in = padding, a parameter has a token name and a value
+	return ok && name != "" && !strings.ContainsAny(name, " \t") && strings.Trim(argument, "=") != ""
+}
+
+/**
+ * @return the challenges of all WWW-Authenticate headers
+ */
+func (h *Headers) GetWWWAuthenticate() []Challenge {
+	challenges := make([]Challenge, 0)
+	for _, value := range h.Get(WWW_AUTHENTICATE) {
+		challenges = append(challenges, ParseChallenges(value)...)
+	}
+	return challenges
+}
+
+/**
+ * @param challenges the challenges, one header each
+ */
+func (h *Headers) SetWWWAuthenticate(challenges ...Challenge) {
+	h.remove(WWW_AUTHENTICATE)
+	for _, challenge := range challenges {
+		h.Add(WWW_AUTHENTICATE, challenge.String())
+	}
+}
+
+/**
+ * Split a header value at sep, except inside quoted strings
+ */
+func splitQuoted(value string, sep rune) []string {
+	parts := make([]string, 0)
+	var part strings.Builder
+	quoted, escaped := false, false
+	for _, r := range value {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case r == sep && !quoted:
+			parts = append(parts, strings.TrimSpace(part.String()))
+			part.Reset()
+			continue
+		}
+		part.WriteRune(r)
+	}
+	return append(parts, strings.TrimSpace(part.String()))
+}
+
+/**
+ * Read a quoted-string of RFC 9110: a backslash takes the next character literally.
+ * A value which is not quoted is returned as it is, a missing closing quote ends the string at the end of value.
+ */
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' {
+		return value
+	}
+	var unquoted strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch c := value[i]; {
+		case c == '"':
+			return unquoted.String()
+		case c == '\\' && i+1 < len(value):
+			i++
+			unquoted.WriteByte(value[i])
+		default:
+			unquoted.WriteByte(c)
+		}
+	}
+	return unquoted.String()
+}
+
+/**
+ * Write value as a quoted-string of RFC 9110, escaping only the quote and the backslash
+ */
+func quoteString(value string) string {
+	var quoted strings.Builder
+	quoted.Grow(len(value) + 2)
+	quoted.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
+			quoted.WriteByte('\\')
+		}
+		quoted.WriteByte(value[i])
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+/**
+ * @return value as it is when it is a token, else as a quoted-string
+ */
+func quoteIfNeeded(value string) string {
+	if value == "" {
+		return quoteString(value)
+	}
+	for i := 0; i < len(value); i++ {
+		if !isTokenChar(value[i]) {
+			return quoteString(value)
+		}
+	}
+	return value
+}
+
+// the characters of an RFC 9110 token
+func isTokenChar(c byte) bool {
+	if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+/**
+ * @param name a header name
+ * @return the name in the usual casing, e.g. content-type becomes Content-Type and etag becomes ETag
+ */
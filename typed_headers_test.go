@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestUnquoteFollowsRfc9110(t *testing.T) {
+	cases := map[string]string{
+		`token`:                 `token`,
+		`"plain"`:               `plain`,
+		`"say \"hi\""`:          `say "hi"`,
+		`"back\\slash"`:         `back\slash`,
+		`"C:\path\new"`:         `C:pathnew`,
+		`"unicode \u00e9"`:      `unicode u00e9`,
+		`"tab	inside"`:          "tab\tinside",
+		`"unterminated`:         `unterminated`,
+		`"closed" and trailing`: `closed`,
+	}
+	for quoted, want := range cases {
+		if got := unquote(quoted); got != want {
+			t.Errorf("unquote(%s) = %q, want %q", quoted, got, want)
+		}
+	}
+}
+
+func TestQuoteStringEscapesOnlyQuoteAndBackslash(t *testing.T) {
+	cases := map[string]string{
+		``:           `""`,
+		`say "hi"`:   `"say \"hi\""`,
+		`back\slash`: `"back\\slash"`,
+		"tab\tcafé":  "\"tab\tcafé\"",
+		"new\\nline": `"new\\nline"`,
+	}
+	for value, want := range cases {
+		got := quoteString(value)
+		if got != want {
+			t.Errorf("quoteString(%q) = %s, want %s", value, got, want)
+		}
+		if back := unquote(got); back != value {
+			t.Errorf("round trip of %q gave %q", value, back)
+		}
+	}
+}
+
+func TestChallengeRoundTrip(t *testing.T) {
+	challenge := Challenge{Scheme: "Bearer", Params: map[string]string{"realm": `a "quoted" realm, with é`, "error": "invalid_token"}}
+	value := challenge.String()
+	if value != `Bearer error="invalid_token", realm="a \"quoted\" realm, with é"` {
+		t.Errorf("got %s", value)
+	}
+	parsed := ParseChallenges(value)
+	if len(parsed) != 1 || parsed[0].Realm() != challenge.Params["realm"] || parsed[0].Params["error"] != "invalid_token" {
+		t.Errorf("got %+v", parsed)
+	}
+}
+
+func TestCacheControlQuotesNonTokens(t *testing.T) {
+	control := CacheControl{{Name: "max-age", Value: "60"}, {Name: "private", Value: `Set-Cookie, X-"Id"`}}
+	if got := control.String(); got != `max-age=60, private="Set-Cookie, X-\"Id\""` {
+		t.Errorf("got %s", got)
+	}
+	if got := ParseCacheControl(control.String()); len(got) != 2 || got[1].Value != control[1].Value {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	headers := NewHeaders()
+	if err := headers.SetRetryAfter(-time.Second); err == nil {
+		t.Error("negative delay accepted")
+	}
+	if headers.ContainsKey(RETRY_AFTER) {
+		t.Error("negative delay was sent")
+	}
+	if err := headers.SetRetryAfter(1500 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if delay, ok := headers.GetRetryAfter(time.Now()); !ok || delay != 2*time.Second {
+		t.Errorf("got %v %v", delay, ok)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	headers.Replace(RETRY_AFTER, now.Add(time.Minute).Format(http.TimeFormat))
+	if delay, ok := headers.GetRetryAfter(now); !ok || delay != time.Minute {
+		t.Errorf("got %v %v", delay, ok)
+	}
+}